@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/fingerprint"
+)
+
+// Signals the abuse detector attaches to a review (reviews.moderation_flags)
+const (
+	flagReviewBurst   = "review_burst"
+	flagNewAccount    = "new_account"
+	flagNearDuplicate = "near_duplicate"
+)
+
+// flagWeights decide when a review is held: at holdScore or above. A burst
+// or a new account alone is common (end of semester, first login), both
+// together look like a brigade; copied text is suspicious on its own.
+var flagWeights = map[string]int{
+	flagReviewBurst:   1,
+	flagNewAccount:    1,
+	flagNearDuplicate: 2,
+}
+
+const holdScore = 2
+
+// A burst's ratings must differ from the professor's history by this many
+// stars, once there's enough history to compare against.
+const (
+	burstRatingShift = 1.5
+	burstMinHistory  = 3
+)
+
+// priorReview is what the detector needs from a professor's other reviews.
+// Held reviews count too, so a brigade can't hide behind the first few
+// being held.
+type priorReview struct {
+	Rating    float64 `json:"rating"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// assessment is the abuse detector's verdict on a review.
+type assessment struct {
+	Flags     []string
+	Hold      bool
+	Duplicate *duplicateMatch // closest near-duplicate, see duplicates.go
+}
+
+// assessReview flags a new review of professorID whose comment has
+// signature sig, and decides whether to hold it for moderation.
+// accountCreated is zero when the token doesn't say.
+func assessReview(ctx context.Context, professorID string, input ReviewInput, sig fingerprint.Signature, accountCreated time.Time) (assessment, error) {
+	var a assessment
+
+	var prior []priorReview
+	path := fmt.Sprintf("reviews?professor_id=eq.%s&select=rating,created_at&order=created_at.desc", professorID)
+	if err := supabase.get(ctx, "abuse_fetch_reviews", path, &prior); err != nil {
+		return a, err
+	}
+
+	now := time.Now()
+	if isBurst(prior, input.Rating, now) {
+		a.Flags = append(a.Flags, flagReviewBurst)
+	}
+	if !accountCreated.IsZero() && now.Sub(accountCreated) < cfg.AbuseNewAccountAge {
+		a.Flags = append(a.Flags, flagNewAccount)
+	}
+
+	duplicate, err := findNearDuplicate(ctx, input.Comment, sig, 0)
+	if err != nil {
+		return a, err
+	}
+	if duplicate != nil {
+		a.Duplicate = duplicate
+		a.Flags = append(a.Flags, flagNearDuplicate)
+	}
+
+	score := 0
+	for _, flag := range a.Flags {
+		score += flagWeights[flag]
+	}
+	a.Hold = score >= holdScore
+	return a, nil
+}
+
+// isBurst reports whether the professor is getting far more reviews within
+// ABUSE_BURST_WINDOW than their history predicts, rated unlike before.
+func isBurst(prior []priorReview, rating float64, now time.Time) bool {
+	window := cfg.AbuseBurstWindow
+	windowStart := now.Add(-window)
+
+	recentRatings := []float64{rating}
+	var olderRatings []float64
+	var oldest time.Time
+	for _, r := range prior {
+		created, err := time.Parse(time.RFC3339Nano, r.CreatedAt)
+		if err != nil {
+			continue
+		}
+		if created.After(windowStart) {
+			recentRatings = append(recentRatings, r.Rating)
+			continue
+		}
+		olderRatings = append(olderRatings, r.Rating)
+		if oldest.IsZero() || created.Before(oldest) {
+			oldest = created
+		}
+	}
+
+	if len(recentRatings) < cfg.AbuseBurstMinReviews {
+		return false
+	}
+
+	// Reviews per window over the professor's history before this one
+	var expected float64
+	if len(olderRatings) > 0 {
+		windows := math.Max(float64(windowStart.Sub(oldest))/float64(window), 1)
+		expected = float64(len(olderRatings)) / windows
+	}
+	if float64(len(recentRatings)) < cfg.AbuseBurstFactor*expected {
+		return false
+	}
+
+	if len(olderRatings) < burstMinHistory {
+		return true
+	}
+	return math.Abs(mean(recentRatings)-mean(olderRatings)) >= burstRatingShift
+}
+
+func mean(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total / float64(len(values))
+}
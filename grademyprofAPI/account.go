@@ -0,0 +1,279 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/fiberkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/Koifish2004/ProfessorWeb/middleware"
+	"github.com/Koifish2004/ProfessorWeb/webhook"
+	"github.com/gofiber/fiber/v2"
+)
+
+// anonymousName replaces the student name on anonymized reviews.
+const anonymousName = "Anonymous"
+
+// Export is everything we store about a user.
+type Export struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	User       Me               `json:"user"`
+	Reviews    []Review         `json:"reviews"`
+	Revisions  []ReviewRevision `json:"revisions"`
+}
+
+// exportMyData returns the signed-in user's data as one JSON document, or
+// with format=zip as a ZIP archive of user.json, reviews.json and
+// revisions.json.
+func exportMyData(c *fiber.Ctx) error {
+	format := c.Query("format", "json")
+	if format != "json" && format != "zip" {
+		return problem.BadRequest(problem.CodeInvalidParameter, "format must be json or zip")
+	}
+
+	export, err := collectExport(c.UserContext(), currentUser(c))
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "data export failed", "err", err)
+		return problem.Upstream("Failed to export your data")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	if format == "json" {
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="grademyprof-export.json"`)
+		return c.JSON(export)
+	}
+
+	archive, err := zipExport(export)
+	if err != nil {
+		return problem.Internal("Failed to export your data")
+	}
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="grademyprof-export.zip"`)
+	return c.Send(archive)
+}
+
+// collectExport gathers user's reviews, deleted ones included, and every
+// revision they wrote or that belongs to one of their reviews.
+func collectExport(ctx context.Context, user Me) (Export, error) {
+	export := Export{ExportedAt: time.Now().UTC(), User: user, Reviews: []Review{}, Revisions: []ReviewRevision{}}
+
+	reviewer, err := findReviewer(ctx, user.Email)
+	if err != nil {
+		return export, err
+	}
+	export.User.Reviewer = reviewer
+
+	path := fmt.Sprintf("reviews?user_email=eq.%s&order=created_at.asc", url.QueryEscape(user.Email))
+	if err := supabase.get(ctx, "list_user_reviews", path, &export.Reviews); err != nil {
+		return export, err
+	}
+
+	var own, edited []ReviewRevision
+	if ids := reviewIDs(export.Reviews); len(ids) > 0 {
+		path := fmt.Sprintf("review_revisions?review_id=in.(%s)&order=review_id.asc,revision.asc", joinIDs(ids))
+		if err := supabaseService.get(ctx, "list_revisions", path, &own); err != nil {
+			return export, err
+		}
+	}
+	path = fmt.Sprintf("review_revisions?editor_email=eq.%s&order=review_id.asc,revision.asc", url.QueryEscape(user.Email))
+	if err := supabaseService.get(ctx, "list_revisions", path, &edited); err != nil {
+		return export, err
+	}
+
+	seen := map[[2]int]bool{}
+	for _, revision := range append(own, edited...) {
+		key := [2]int{revision.ReviewID, revision.Revision}
+		if !seen[key] {
+			seen[key] = true
+			export.Revisions = append(export.Revisions, revision)
+		}
+	}
+	return export, nil
+}
+
+func zipExport(export Export) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"user.json", export.User},
+		{"reviews.json", export.Reviews},
+		{"revisions.json", export.Revisions},
+	}
+	for _, file := range files {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deleteMe deletes the signed-in user's account: their reviews are deleted
+// or anonymized (ACCOUNT_DELETION_REVIEWS), their name and email are
+// scrubbed from the edit history, their reviews are redacted in webhook
+// payloads and audit snapshots (redactReview), their reviewer identity is
+// deleted, and grademyprofAuth revokes all of their tokens. The audit log
+// only ever named them by pseudonym (audit.Pseudonym), so it keeps its
+// entries. Every step can be repeated, so a failed request can be retried.
+func deleteMe(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	email, _ := c.Locals("user_email").(string)
+	filter := "user_email=eq." + url.QueryEscape(email)
+
+	var reviews []Review
+	if err := supabase.get(ctx, "list_user_reviews", "reviews?"+filter, &reviews); err != nil {
+		return problem.Upstream("Failed to delete your account")
+	}
+	// Looked up first: it's gone by the time their reviews are redacted
+	reviewer, err := findReviewer(ctx, email)
+	if err != nil {
+		return problem.Upstream("Failed to delete your account")
+	}
+
+	// A fresh ID per account: anonymized reviews can't be linked back to the
+	// email, and stay unique per professor
+	anonymousID, err := newAnonymousID()
+	if err != nil {
+		return problem.Internal("Failed to delete your account")
+	}
+
+	keepComments := cfg.AccountDeletionReviews == "anonymize"
+	var touched []Review
+	if keepComments {
+		// Reviews the user already deleted go for good
+		err = supabase.remove(ctx, "delete_user_reviews", "reviews?"+filter+"&deleted_at=not.is.null&select=id", &touched)
+		if err == nil {
+			err = supabase.patch(ctx, "anonymize_user_reviews", "reviews?"+filter,
+				fiber.Map{"user_email": anonymousID, "student_name": anonymousName, "reviewer_name": anonymousName}, &touched)
+		}
+	} else {
+		// Their revisions go with them (ON DELETE CASCADE)
+		err = supabase.remove(ctx, "delete_user_reviews", "reviews?"+filter+"&select=id", &touched)
+	}
+	if err == nil {
+		// Revisions can't be changed with the anon key: only this function,
+		// as the service, can drop their name from the history of the
+		// reviews now under anonymousID and their email from edits they
+		// made (migrations/017_revision_anonymization.sql)
+		var revisions int
+		err = supabaseService.rpc(ctx, "anonymize_revisions", "anonymize_review_revisions",
+			fiber.Map{"email": email, "anonymous_id": anonymousID}, &revisions)
+	}
+	if err == nil {
+		// Deliveries can be replayed and audit snapshots read by admins:
+		// both drop the reviews' author, and comments unless the reviews
+		// stay up (migrations/022_account_redaction.sql)
+		args := fiber.Map{"review_ids": reviewIDs(reviews), "reviewer_id": nil, "keep_comments": keepComments}
+		if reviewer != nil {
+			args["reviewer_id"] = reviewer.ID
+		}
+		var redacted int
+		err = supabaseService.rpc(ctx, "redact_account_data", "redact_account_data", args, &redacted)
+	}
+	if err == nil {
+		// Anonymized reviews lose their reviewer_id with it (ON DELETE SET NULL)
+		var reviewers []Reviewer
+		err = supabaseService.remove(ctx, "delete_reviewer", "reviewers?email=eq."+url.QueryEscape(email)+"&select=id", &reviewers)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "account deletion failed", "err", err)
+		return problem.Upstream("Failed to delete your account")
+	}
+
+	// Anonymizing only renames the reviews; deleting takes them out of
+	// listings
+	if keepComments {
+		for _, review := range touched {
+			if isPublic(&review) {
+				emitWebhook(ctx, webhook.ReviewUpdated, redactReview(review, true))
+			}
+		}
+	} else {
+		for _, review := range reviews {
+			review = redactReview(review, false)
+			emitReviewChange(ctx, &review, nil)
+		}
+	}
+
+	var professorIDs []int
+	for _, review := range reviews {
+		if !slices.Contains(professorIDs, review.ProfessorID) {
+			professorIDs = append(professorIDs, review.ProfessorID)
+		}
+	}
+	for _, id := range professorIDs {
+		professorID := strconv.Itoa(id)
+		invalidateCache("reviews:" + professorID)
+		scheduleStatsUpdate(ctx, professorID)
+	}
+
+	if err := middleware.RevokeTokens(ctx, c.Get(fiber.HeaderAuthorization)); err != nil {
+		slog.ErrorContext(ctx, "token revocation failed", "err", err)
+		return problem.Upstream("Your reviews were removed but signing you out everywhere failed, try again")
+	}
+
+	slog.InfoContext(ctx, "account deleted", "reviews", len(reviews), "reviews_action", cfg.AccountDeletionReviews)
+	// Counts only: review IDs or the anonymous ID would tie anonymized
+	// reviews back to the email
+	entry := fiberkit.AuditEntry(c, audit.ActionAccountDelete, "account", audit.Pseudonym(email))
+	entry.After = audit.Snapshot(fiber.Map{"reviews": len(reviews), "reviews_action": cfg.AccountDeletionReviews})
+	recordAudit(ctx, entry)
+	return c.JSON(fiber.Map{
+		"message":        "Account deleted",
+		"reviews":        len(reviews),
+		"reviews_action": cfg.AccountDeletionReviews,
+	})
+}
+
+func newAnonymousID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "deleted:" + hex.EncodeToString(b), nil
+}
+
+// redactReview is review as redact_review_json leaves it in webhook payloads
+// and audit snapshots: no name or reviewer, and no comment unless
+// keepComment, for reviews that stay up anonymized.
+func redactReview(review Review, keepComment bool) Review {
+	review.StudentName = anonymousName
+	review.ReviewerName = anonymousName
+	review.ReviewerID = nil
+	if !keepComment {
+		review.Comment = ""
+	}
+	return review
+}
+
+func reviewIDs(reviews []Review) []int {
+	ids := make([]int, len(reviews))
+	for i, review := range reviews {
+		ids[i] = review.ID
+	}
+	return ids
+}
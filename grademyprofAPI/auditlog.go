@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxAuditPage caps how many audit entries one request returns.
+const maxAuditPage = 200
+
+// supabaseAudit stores audit entries in the audit_log table, which only
+// the service role may read or append to
+// (migrations/019_audit_log_service_only.sql).
+type supabaseAudit struct{}
+
+func (supabaseAudit) Append(ctx context.Context, entry audit.Entry) error {
+	var inserted []audit.Entry
+	return supabaseService.insert(ctx, "record_audit", "audit_log?select=id", entry, &inserted)
+}
+
+// recordAudit stores entry, counting it when it only made it to the log.
+func recordAudit(ctx context.Context, entry audit.Entry) {
+	if err := audit.Record(ctx, supabaseAudit{}, entry); err != nil {
+		auditFailures.Inc()
+	}
+}
+
+// auditFilters are the audit_log columns admins can filter on exactly.
+var auditFilters = []string{"service", "actor", "target_type", "target_id", "request_id", "ip"}
+
+// pseudonymFilters hold audit.Pseudonyms; admins may filter on the email or
+// IP itself, which is looked up by its pseudonym.
+var pseudonymFilters = map[string]bool{"actor": true, "target_id": true, "ip": true}
+
+// getAuditLog lists audit entries from both services, newest first, a page
+// at a time: pass the last id seen as before= for the next page.
+func getAuditLog(c *fiber.Ctx) error {
+	query := []string{"order=id.desc"}
+
+	for _, column := range auditFilters {
+		value := c.Query(column)
+		if value == "" {
+			continue
+		}
+		if pseudonymFilters[column] && (strings.Contains(value, "@") || net.ParseIP(value) != nil) {
+			value = audit.Pseudonym(value)
+		}
+		query = append(query, column+"=eq."+url.QueryEscape(value))
+	}
+	// action=review.delete,review.restore matches either
+	if actions := c.Query("action"); actions != "" {
+		var escaped []string
+		for _, action := range strings.Split(actions, ",") {
+			escaped = append(escaped, url.QueryEscape(strings.TrimSpace(action)))
+		}
+		query = append(query, "action=in.("+strings.Join(escaped, ",")+")")
+	}
+
+	for _, bound := range []struct{ param, op string }{{"since", "gte"}, {"until", "lt"}} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return problem.BadRequest(problem.CodeInvalidParameter, bound.param+" must be an RFC 3339 time")
+		}
+		query = append(query, "created_at="+bound.op+"."+url.QueryEscape(t.UTC().Format(time.RFC3339Nano)))
+	}
+
+	if before := c.Query("before"); before != "" {
+		id, err := strconv.ParseInt(before, 10, 64)
+		if err != nil || id < 1 {
+			return problem.BadRequest(problem.CodeInvalidParameter, "before must be an entry ID")
+		}
+		query = append(query, fmt.Sprintf("id=lt.%d", id))
+	}
+
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > maxAuditPage {
+		return problem.BadRequest(problem.CodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", maxAuditPage))
+	}
+	query = append(query, fmt.Sprintf("limit=%d", limit))
+
+	entries := []audit.Entry{}
+	if err := supabaseService.get(c.UserContext(), "list_audit_log", "audit_log?"+strings.Join(query, "&"), &entries); err != nil {
+		return problem.Upstream("Failed to fetch the audit log")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(entries)
+}
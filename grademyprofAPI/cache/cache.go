@@ -0,0 +1,15 @@
+package cache
+
+import "time"
+
+// Cache stores rendered responses by key. Entries carry tags so that a
+// write can drop every response that depends on the data it touched
+// without knowing the exact keys (e.g. "professor:12" or "campus:goa").
+//
+// LRU is the in-process implementation; a shared backend such as Redis
+// only needs to satisfy this interface to be dropped in.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration, tags ...string)
+	Invalidate(tags ...string)
+}
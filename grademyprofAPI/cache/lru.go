@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+// LRU is an in-memory Cache that evicts the least recently used entry once
+// it holds capacity entries. Expired entries are dropped lazily on Get.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+	tagged   map[string]map[string]struct{}
+}
+
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		tagged:   make(map[string]map[string]struct{}),
+	}
+}
+
+func (l *LRU) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		l.remove(el)
+		return nil, false
+	}
+
+	l.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (l *LRU) Set(key string, value []byte, ttl time.Duration, tags ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		l.remove(el)
+	}
+
+	e := &entry{key: key, value: value, expires: time.Now().Add(ttl), tags: tags}
+	l.items[key] = l.order.PushFront(e)
+	for _, tag := range tags {
+		if l.tagged[tag] == nil {
+			l.tagged[tag] = make(map[string]struct{})
+		}
+		l.tagged[tag][key] = struct{}{}
+	}
+
+	for l.order.Len() > l.capacity {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *LRU) Invalidate(tags ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range l.tagged[tag] {
+			if el, ok := l.items[key]; ok {
+				l.remove(el)
+			}
+		}
+		delete(l.tagged, tag)
+	}
+}
+
+// Len reports how many entries are cached, including expired ones that
+// have not been looked up since.
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// remove must be called with mu held.
+func (l *LRU) remove(el *list.Element) {
+	e := el.Value.(*entry)
+	l.order.Remove(el)
+	delete(l.items, e.key)
+	for _, tag := range e.tags {
+		delete(l.tagged[tag], e.key)
+		if len(l.tagged[tag]) == 0 {
+			delete(l.tagged, tag)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		touch   string
+		evicted string
+	}{
+		{"oldest goes first", "", "a"},
+		{"reading an entry keeps it", "a", "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLRU(2)
+			l.Set("a", []byte("a"), time.Minute)
+			l.Set("b", []byte("b"), time.Minute)
+			if tt.touch != "" {
+				l.Get(tt.touch)
+			}
+			l.Set("c", []byte("c"), time.Minute)
+
+			if l.Len() != 2 {
+				t.Errorf("Len() = %d, want 2", l.Len())
+			}
+			for _, key := range []string{"a", "b", "c"} {
+				if _, ok := l.Get(key); ok == (key == tt.evicted) {
+					t.Errorf("Get(%q) found: %t, want %t", key, ok, key != tt.evicted)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	l := NewLRU(10)
+	l.Set("fresh", []byte("1"), time.Minute)
+	l.Set("stale", []byte("2"), -time.Second)
+
+	if value, ok := l.Get("fresh"); !ok || string(value) != "1" {
+		t.Errorf("Get(fresh) = %q, %t, want 1, true", value, ok)
+	}
+	if _, ok := l.Get("stale"); ok {
+		t.Error("Get(stale) found an expired entry")
+	}
+	if l.Len() != 1 {
+		t.Errorf("Len() = %d, want 1 once the expired entry was looked up", l.Len())
+	}
+}
+
+func TestLRUInvalidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate []string
+		kept       []string
+		dropped    []string
+	}{
+		{"one tag", []string{"professor:1"}, []string{"campus", "professor:2"}, []string{"reviews:1"}},
+		{"shared tag", []string{"campus:pilani"}, []string{"professor:2"}, []string{"campus", "reviews:1"}},
+		{"several tags", []string{"professor:1", "professor:2"}, []string{"campus"}, []string{"reviews:1", "professor:2"}},
+		{"unknown tag", []string{"professor:3"}, []string{"campus", "reviews:1", "professor:2"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLRU(10)
+			l.Set("campus", []byte("{}"), time.Minute, "campus:pilani")
+			l.Set("reviews:1", []byte("[]"), time.Minute, "professor:1", "campus:pilani")
+			l.Set("professor:2", []byte("{}"), time.Minute, "professor:2")
+
+			l.Invalidate(tt.invalidate...)
+			for _, key := range tt.kept {
+				if _, ok := l.Get(key); !ok {
+					t.Errorf("Get(%q) = not found, want kept", key)
+				}
+			}
+			for _, key := range tt.dropped {
+				if _, ok := l.Get(key); ok {
+					t.Errorf("Get(%q) = found, want dropped", key)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUOverwriteDropsOldTags(t *testing.T) {
+	l := NewLRU(10)
+	l.Set("professor:1", []byte("old"), time.Minute, "campus:pilani")
+	l.Set("professor:1", []byte("new"), time.Minute, "campus:goa")
+
+	l.Invalidate("campus:pilani")
+	if value, ok := l.Get("professor:1"); !ok || string(value) != "new" {
+		t.Errorf("Get(professor:1) = %q, %t, want new, true", value, ok)
+	}
+	l.Invalidate("campus:goa")
+	if _, ok := l.Get("professor:1"); ok {
+		t.Error("Get(professor:1) found an invalidated entry")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/Koifish2004/ProfessorWeb/cache"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	responseCache cache.Cache
+
+	// invalidations counts cache invalidations made by this process. A
+	// request that straddles an invalidation may have read pre-write data,
+	// so cacheResponse refuses to store it.
+	invalidations atomic.Uint64
+)
+
+// cachedResponse is what cacheResponse stores: the JSON body plus the
+// headers conditionalGet needs to answer revalidation from the cache.
+type cachedResponse struct {
+	LastModified string          `json:"last_modified,omitempty"`
+	Body         json.RawMessage `json:"body"`
+}
+
+// cacheResponse serves GET responses from responseCache, keyed by path and
+// query string. Handlers declare what the response depends on with
+// cacheTags so writes can invalidate it.
+func cacheResponse(c *fiber.Ctx) error {
+	key := c.OriginalURL()
+
+	if raw, ok := responseCache.Get(key); ok {
+		var cached cachedResponse
+		if err := json.Unmarshal(raw, &cached); err == nil {
+			c.Set("X-Cache", "HIT")
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			if cached.LastModified != "" {
+				c.Set(fiber.HeaderLastModified, cached.LastModified)
+			}
+			return c.Send(cached.Body)
+		}
+	}
+
+	before := invalidations.Load()
+	if err := c.Next(); err != nil {
+		return err
+	}
+	c.Set("X-Cache", "MISS")
+
+	if c.Response().StatusCode() != fiber.StatusOK || invalidations.Load() != before {
+		return nil
+	}
+
+	tags, _ := c.Locals("cache_tags").([]string)
+	raw, err := json.Marshal(cachedResponse{
+		LastModified: c.GetRespHeader(fiber.HeaderLastModified),
+		Body:         c.Response().Body(),
+	})
+	if err != nil {
+		return nil
+	}
+	responseCache.Set(key, raw, cfg.CacheTTL, tags...)
+	return nil
+}
+
+// cacheTags records which data the current response depends on.
+func cacheTags(c *fiber.Ctx, tags ...string) {
+	existing, _ := c.Locals("cache_tags").([]string)
+	c.Locals("cache_tags", append(existing, tags...))
+}
+
+func invalidateCache(tags ...string) {
+	invalidations.Add(1)
+	responseCache.Invalidate(tags...)
+}
+
+// invalidateProfessor drops everything that shows professorID's stats:
+// the professor itself and the campus list (whose Bayesian priors it also
+// shifts). updated is the professor row returned by the stats PATCH; when
+// it is missing we don't know the campus and drop every list instead.
+func invalidateProfessor(professorID string, updated []Professor) {
+	if len(updated) == 0 {
+		invalidateCache("professor:"+professorID, "professors")
+		return
+	}
+	invalidateCache("professor:"+professorID, "campus:"+updated[0].Campus)
+}
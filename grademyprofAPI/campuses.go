@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// browseListSize is how many professors/courses each ranked list shows.
+	browseListSize = 5
+	// minCourseReviews keeps a single grumpy review from topping the
+	// hardest courses list.
+	minCourseReviews = 2
+)
+
+// AggregateStats are review-weighted averages over a set of professors.
+type AggregateStats struct {
+	ProfessorCount        int     `json:"professor_count"`
+	ReviewCount           int     `json:"review_count"`
+	AverageRating         float64 `json:"average_rating"`
+	AverageDifficulty     float64 `json:"average_difficulty"`
+	WouldTakeAgainPercent int     `json:"would_take_again_percent"`
+}
+
+type DepartmentSummary struct {
+	Department string `json:"department"`
+	AggregateStats
+}
+
+type CampusSummary struct {
+	Campus string `json:"campus"`
+	AggregateStats
+	Departments []DepartmentSummary `json:"departments"`
+}
+
+type CourseDifficulty struct {
+	Course            string  `json:"course"`
+	ReviewCount       int     `json:"review_count"`
+	AverageDifficulty float64 `json:"average_difficulty"`
+	AverageRating     float64 `json:"average_rating"`
+}
+
+type DepartmentPage struct {
+	Campus     string `json:"campus"`
+	Department string `json:"department"`
+	AggregateStats
+	TopRated       []Professor        `json:"top_rated"`
+	MostReviewed   []Professor        `json:"most_reviewed"`
+	HardestCourses []CourseDifficulty `json:"hardest_courses"`
+}
+
+func getCampuses(c *fiber.Ctx) error {
+	var professors []Professor
+	if err := supabase.get(c.UserContext(), "list_all_professors", "professor?select=campus,department,average_rating,review_count,average_difficulty,would_take_again_percent", &professors); err != nil {
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to fetch campuses")
+	}
+
+	return c.JSON(summarizeCampuses(professors))
+}
+
+func getDepartment(c *fiber.Ctx) error {
+	campus, err := url.PathUnescape(c.Params("campus"))
+	if err != nil {
+		return problem.BadRequest(problem.CodeInvalidParameter, "Invalid campus")
+	}
+	department, err := url.PathUnescape(c.Params("dept"))
+	if err != nil {
+		return problem.BadRequest(problem.CodeInvalidParameter, "Invalid department")
+	}
+	campus = strings.ToLower(campus)
+
+	// Department names contain spaces and '&', so rather than escaping them
+	// into a PostgREST filter we fetch the campus (also needed for the
+	// rating priors) and pick the department out here.
+	var campusProfessors []Professor
+	if err := supabase.get(c.UserContext(), "list_professors", fmt.Sprintf("professor?campus=eq.%s", url.QueryEscape(campus)), &campusProfessors); err != nil {
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to fetch professors")
+	}
+
+	var professors []Professor
+	for _, p := range campusProfessors {
+		if strings.EqualFold(p.Department, department) {
+			professors = append(professors, p)
+		}
+	}
+
+	if len(professors) == 0 {
+		return problem.NotFound("Department not found")
+	}
+
+	applyBayesianRatings(professors, campusProfessors)
+
+	ids := make([]int, len(professors))
+	for i, p := range professors {
+		ids[i] = p.ID
+	}
+
+	var reviews []Review
+	if err := supabase.get(c.UserContext(), "list_course_reviews", fmt.Sprintf("reviews?professor_id=in.(%s)&%s&select=course,rating,difficulty,would_take_again", joinIDs(ids), publicOnly), &reviews); err != nil {
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to fetch reviews")
+	}
+
+	page := DepartmentPage{
+		Campus:         campus,
+		Department:     professors[0].Department,
+		AggregateStats: aggregateProfessors(professors),
+		HardestCourses: hardestCourses(reviews),
+	}
+
+	var reviewed []Professor
+	for _, p := range professors {
+		if p.ReviewCount > 0 {
+			reviewed = append(reviewed, p)
+		}
+	}
+	if page.TopRated, err = topProfessors(reviewed, "bayesian"); err != nil {
+		return problem.Internal("Failed to rank professors")
+	}
+	if page.MostReviewed, err = topProfessors(reviewed, "reviews"); err != nil {
+		return problem.Internal("Failed to rank professors")
+	}
+
+	return c.JSON(page)
+}
+
+// aggregateProfessors averages professor stats weighted by review count, so
+// a department's average reflects its reviews rather than its head count.
+func aggregateProfessors(professors []Professor) AggregateStats {
+	stats := AggregateStats{ProfessorCount: len(professors)}
+
+	var totalRating, totalDifficulty, totalWouldTakeAgain float64
+	for _, p := range professors {
+		weight := float64(p.ReviewCount)
+		stats.ReviewCount += p.ReviewCount
+		totalRating += p.AverageRating * weight
+		totalDifficulty += p.AverageDifficulty * weight
+		totalWouldTakeAgain += float64(p.WouldTakeAgainPercent) * weight
+	}
+
+	if stats.ReviewCount == 0 {
+		return stats
+	}
+
+	count := float64(stats.ReviewCount)
+	stats.AverageRating = math.Round(totalRating/count*10) / 10
+	stats.AverageDifficulty = math.Round(totalDifficulty/count*10) / 10
+	stats.WouldTakeAgainPercent = int(math.Round(totalWouldTakeAgain / count))
+	return stats
+}
+
+func summarizeCampuses(professors []Professor) []CampusSummary {
+	byCampus := map[string][]Professor{}
+	for _, p := range professors {
+		byCampus[p.Campus] = append(byCampus[p.Campus], p)
+	}
+
+	summaries := []CampusSummary{}
+	for campus, campusProfessors := range byCampus {
+		byDepartment := map[string][]Professor{}
+		for _, p := range campusProfessors {
+			byDepartment[p.Department] = append(byDepartment[p.Department], p)
+		}
+
+		summary := CampusSummary{
+			Campus:         campus,
+			AggregateStats: aggregateProfessors(campusProfessors),
+			Departments:    []DepartmentSummary{},
+		}
+		for department, deptProfessors := range byDepartment {
+			summary.Departments = append(summary.Departments, DepartmentSummary{
+				Department:     department,
+				AggregateStats: aggregateProfessors(deptProfessors),
+			})
+		}
+		sort.Slice(summary.Departments, func(i, j int) bool {
+			return summary.Departments[i].Department < summary.Departments[j].Department
+		})
+
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Campus < summaries[j].Campus
+	})
+	return summaries
+}
+
+func topProfessors(professors []Professor, by string) ([]Professor, error) {
+	top := append([]Professor{}, professors...)
+	if err := sortProfessors(top, by); err != nil {
+		return nil, err
+	}
+	if len(top) > browseListSize {
+		top = top[:browseListSize]
+	}
+	return top, nil
+}
+
+func hardestCourses(reviews []Review) []CourseDifficulty {
+	byCourse := map[string][]Review{}
+	for _, r := range reviews {
+		course := normalizeCourse(r.Course)
+		byCourse[course] = append(byCourse[course], r)
+	}
+
+	courses := []CourseDifficulty{}
+	for course, courseReviews := range byCourse {
+		if len(courseReviews) < minCourseReviews {
+			continue
+		}
+		stats := summarizeCourse(courseReviews)
+		courses = append(courses, CourseDifficulty{
+			Course:            course,
+			ReviewCount:       stats.ReviewCount,
+			AverageDifficulty: stats.AverageDifficulty,
+			AverageRating:     stats.AverageRating,
+		})
+	}
+
+	sort.Slice(courses, func(i, j int) bool {
+		if courses[i].AverageDifficulty != courses[j].AverageDifficulty {
+			return courses[i].AverageDifficulty > courses[j].AverageDifficulty
+		}
+		return courses[i].ReviewCount > courses[j].ReviewCount
+	})
+	if len(courses) > browseListSize {
+		courses = courses[:browseListSize]
+	}
+	return courses
+}
@@ -0,0 +1,67 @@
+// Command webhook-receiver is a local endpoint for trying out webhooks. It
+// checks each delivery's signature and prints the event:
+//
+//	go run ./cmd/webhook-receiver -secret whsec_... -addr :9000
+//
+// then subscribe http://localhost:9000/ through POST /api/admin/webhooks.
+// -fail makes it answer 500, to watch retries back off, and -delay makes it
+// slow, to watch deliveries time out.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/webhook"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	secret := flag.String("secret", "", "the subscription's signing secret (required)")
+	tolerance := flag.Duration("tolerance", 5*time.Minute, "oldest signature to accept")
+	fail := flag.Bool("fail", false, "answer every delivery with a 500")
+	delay := flag.Duration("delay", 0, "wait this long before answering")
+	flag.Parse()
+	if *secret == "" {
+		log.Fatal("-secret is required")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "can't read body", http.StatusBadRequest)
+			return
+		}
+		delivery := r.Header.Get(webhook.DeliveryHeader)
+		if err := webhook.Verify(*secret, r.Header.Get(webhook.SignatureHeader), body, *tolerance); err != nil {
+			log.Printf("delivery %s refused: %v", delivery, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		var event webhook.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			log.Printf("delivery %s refused: %v", delivery, err)
+			http.Error(w, "body is not an event", http.StatusBadRequest)
+			return
+		}
+		var data bytes.Buffer
+		json.Indent(&data, event.Data, "  ", "  ")
+		log.Printf("delivery %s: %s %s at %s\n  %s", delivery, event.Type, event.ID, event.CreatedAt.Format(time.RFC3339), data.String())
+
+		time.Sleep(*delay)
+		if *fail {
+			http.Error(w, "failing on purpose (-fail)", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxCompare caps how many professors fit in one comparison table.
+const maxCompare = 5
+
+// CourseStats summarizes the reviews a professor received for one course.
+type CourseStats struct {
+	ProfessorID           int     `json:"professor_id"`
+	ReviewCount           int     `json:"review_count"`
+	AverageRating         float64 `json:"average_rating"`
+	AverageDifficulty     float64 `json:"average_difficulty"`
+	WouldTakeAgainPercent int     `json:"would_take_again_percent"`
+}
+
+// SharedCourse is one row of the comparison table: a course taught by at
+// least two of the compared professors, with stats in request order.
+type SharedCourse struct {
+	Course string        `json:"course"`
+	Stats  []CourseStats `json:"stats"`
+}
+
+// ComparedProfessor is one column of the comparison table.
+type ComparedProfessor struct {
+	Professor Professor `json:"professor"`
+	// RatingHistogram counts reviews per star, index 0 is 1 star.
+	RatingHistogram     [5]int  `json:"rating_histogram"`
+	DifficultyHistogram [5]int  `json:"difficulty_histogram"`
+	TopReview           *Review `json:"top_review"`
+}
+
+type Comparison struct {
+	Professors    []ComparedProfessor `json:"professors"`
+	SharedCourses []SharedCourse      `json:"shared_courses"`
+}
+
+func compareProfessors(c *fiber.Ctx) error {
+	ids, err := parseCompareIDs(c.Query("ids"))
+	if err != nil {
+		return problem.BadRequest(problem.CodeInvalidParameter, err.Error())
+	}
+
+	idList := joinIDs(ids)
+
+	var professors []Professor
+	if err := supabase.get(c.UserContext(), "compare_professors", fmt.Sprintf("professor?id=in.(%s)", idList), &professors); err != nil {
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to fetch professors")
+	}
+
+	if len(professors) != len(ids) {
+		return problem.NotFound("One or more professors not found")
+	}
+
+	var reviews []Review
+	if err := supabase.get(c.UserContext(), "compare_reviews", fmt.Sprintf("reviews?professor_id=in.(%s)&%s&order=created_at.desc", idList, publicOnly), &reviews); err != nil {
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to fetch reviews")
+	}
+
+	campuses := map[string]bool{}
+	for _, p := range professors {
+		campuses[p.Campus] = true
+	}
+	var campusList []string
+	for campus := range campuses {
+		campusList = append(campusList, url.QueryEscape(campus))
+	}
+
+	var peers []Professor
+	peersPath := fmt.Sprintf("professor?campus=in.(%s)&select=campus,department,average_rating,review_count", strings.Join(campusList, ","))
+	if err := supabase.get(c.UserContext(), "list_campus_peers", peersPath, &peers); err != nil {
+		slog.WarnContext(c.UserContext(), "failed to fetch rating priors", "err", err)
+		peers = professors
+	}
+	applyBayesianRatings(professors, peers)
+
+	return c.JSON(buildComparison(ids, professors, reviews))
+}
+
+// parseCompareIDs parses "1,2,3" into distinct professor IDs, keeping the
+// order the client asked for so columns line up with the request.
+func parseCompareIDs(raw string) ([]int, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("IDs are required, e.g. ?ids=1,2")
+	}
+
+	seen := map[int]bool{}
+	var ids []int
+	for _, part := range strings.Split(raw, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("Invalid professor ID %q", part)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) < 2 {
+		return nil, fmt.Errorf("At least 2 professors are needed to compare")
+	}
+	if len(ids) > maxCompare {
+		return nil, fmt.Errorf("At most %d professors can be compared at once", maxCompare)
+	}
+	return ids, nil
+}
+
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+func buildComparison(ids []int, professors []Professor, reviews []Review) Comparison {
+	byID := map[int]Professor{}
+	for _, p := range professors {
+		byID[p.ID] = p
+	}
+
+	reviewsByProfessor := map[int][]Review{}
+	for _, r := range reviews {
+		reviewsByProfessor[r.ProfessorID] = append(reviewsByProfessor[r.ProfessorID], r)
+	}
+
+	comparison := Comparison{
+		Professors:    []ComparedProfessor{},
+		SharedCourses: []SharedCourse{},
+	}
+
+	// course -> professor ID -> reviews
+	courses := map[string]map[int][]Review{}
+
+	for _, id := range ids {
+		profReviews := reviewsByProfessor[id]
+		column := ComparedProfessor{
+			Professor: byID[id],
+			TopReview: mostHelpfulReview(profReviews, byID[id].AverageRating),
+		}
+
+		for _, r := range profReviews {
+			column.RatingHistogram[starBucket(r.Rating)]++
+			column.DifficultyHistogram[starBucket(r.Difficulty)]++
+
+			course := normalizeCourse(r.Course)
+			if courses[course] == nil {
+				courses[course] = map[int][]Review{}
+			}
+			courses[course][id] = append(courses[course][id], r)
+		}
+
+		comparison.Professors = append(comparison.Professors, column)
+	}
+
+	for course, byProfessor := range courses {
+		if len(byProfessor) < 2 {
+			continue
+		}
+
+		shared := SharedCourse{Course: course}
+		for _, id := range ids {
+			stats := summarizeCourse(byProfessor[id])
+			stats.ProfessorID = id
+			shared.Stats = append(shared.Stats, stats)
+		}
+		comparison.SharedCourses = append(comparison.SharedCourses, shared)
+	}
+
+	sort.Slice(comparison.SharedCourses, func(i, j int) bool {
+		return comparison.SharedCourses[i].Course < comparison.SharedCourses[j].Course
+	})
+
+	return comparison
+}
+
+// normalizeCourse lets "cs f111" and "CS F111 " count as the same course.
+func normalizeCourse(course string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(course), " "))
+}
+
+// starBucket maps a 1.0-5.0 score to a histogram index.
+func starBucket(score float64) int {
+	bucket := int(math.Round(score)) - 1
+	if bucket < 0 {
+		return 0
+	}
+	if bucket > 4 {
+		return 4
+	}
+	return bucket
+}
+
+func summarizeCourse(reviews []Review) CourseStats {
+	stats := CourseStats{ReviewCount: len(reviews)}
+	if len(reviews) == 0 {
+		return stats
+	}
+
+	var totalRating, totalDifficulty float64
+	var wouldTakeAgainCount int
+	for _, r := range reviews {
+		totalRating += r.Rating
+		totalDifficulty += r.Difficulty
+		if r.WouldTakeAgain {
+			wouldTakeAgainCount++
+		}
+	}
+
+	count := float64(len(reviews))
+	stats.AverageRating = math.Round(totalRating/count*10) / 10
+	stats.AverageDifficulty = math.Round(totalDifficulty/count*10) / 10
+	stats.WouldTakeAgainPercent = int(float64(wouldTakeAgainCount) / count * 100)
+	return stats
+}
+
+// mostHelpfulReview picks the review that best represents the professor.
+// There are no helpfulness votes yet, so we take the written review whose
+// rating is closest to the professor's average, preferring longer comments
+// and then newer ones (reviews arrive newest first).
+func mostHelpfulReview(reviews []Review, average float64) *Review {
+	var best *Review
+	for i := range reviews {
+		r := &reviews[i]
+		if strings.TrimSpace(r.Comment) == "" {
+			continue
+		}
+		if best == nil {
+			best = r
+			continue
+		}
+
+		distance := math.Abs(r.Rating - average)
+		bestDistance := math.Abs(best.Rating - average)
+		if distance < bestDistance || (distance == bestDistance && len(r.Comment) > len(best.Comment)) {
+			best = r
+		}
+	}
+	return best
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// How long a CDN may keep serving a response while it refetches it. The
+// max-age values come from HTTP_MAX_AGE and HTTP_S_MAXAGE, see config.
+const staleWhileRevalidate = 60 * time.Second
+
+// conditionalGet adds a strong ETag and Cache-Control to successful GET
+// responses and answers 304 Not Modified when the client's If-None-Match
+// or If-Modified-Since shows it already has this version. Handlers set
+// Last-Modified themselves with setLastModified.
+func conditionalGet(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	if c.Response().StatusCode() != fiber.StatusOK {
+		return nil
+	}
+
+	sum := sha256.Sum256(c.Response().Body())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf(
+		"public, max-age=%d, s-maxage=%d, stale-while-revalidate=%d",
+		int(cfg.HTTPMaxAge.Seconds()), int(cfg.HTTPSharedMaxAge.Seconds()), int(staleWhileRevalidate.Seconds()),
+	))
+
+	if notModified(c, etag) {
+		c.Context().ResetBody()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return nil
+}
+
+// notModified follows RFC 9110 13.2.2: If-None-Match wins when present,
+// otherwise If-Modified-Since is compared against Last-Modified.
+func notModified(c *fiber.Ctx, etag string) bool {
+	if noneMatch := c.Get(fiber.HeaderIfNoneMatch); noneMatch != "" {
+		for _, candidate := range strings.Split(noneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	modifiedSince := c.Get(fiber.HeaderIfModifiedSince)
+	lastModified := c.GetRespHeader(fiber.HeaderLastModified)
+	if modifiedSince == "" || lastModified == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(modifiedSince)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// professorStamp is when a professor, or any of their reviews, last
+// changed. The database bumps updated_at on every write to either (see
+// migrations/015_professor_updated_at.sql), deletes and moderation
+// included, so it's safe to answer If-Modified-Since from it.
+type professorStamp struct {
+	UpdatedAt string `json:"updated_at"`
+}
+
+// setLastModified sets Last-Modified to the newest of the given stamps.
+func setLastModified(c *fiber.Ctx, stamps []professorStamp) {
+	var newest time.Time
+	for _, s := range stamps {
+		written, err := time.Parse(time.RFC3339Nano, s.UpdatedAt)
+		if err == nil && written.After(newest) {
+			newest = written
+		}
+	}
+
+	if !newest.IsZero() {
+		c.Set(fiber.HeaderLastModified, newest.UTC().Format(http.TimeFormat))
+	}
+}
+
+// professorsUpdatedAt fetches the newest stamp among professorIDs.
+func professorsUpdatedAt(ctx context.Context, professorIDs []int) ([]professorStamp, error) {
+	var stamps []professorStamp
+	if len(professorIDs) == 0 {
+		return stamps, nil
+	}
+	path := fmt.Sprintf("professor?id=in.(%s)&select=updated_at&order=updated_at.desc&limit=1", joinIDs(professorIDs))
+	err := supabase.get(ctx, "professors_updated_at", path, &stamps)
+	return stamps, err
+}
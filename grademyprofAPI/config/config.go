@@ -0,0 +1,276 @@
+// Package config loads grademyprofAPI's settings into a typed Config. Every
+// setting is an environment variable (see README.md), can also come from a
+// .env-style file passed with --config, and falls back to the default in
+// its tag. Run the API with --print-config to see the effective values.
+package config
+
+import (
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/envconfig"
+	"github.com/joho/godotenv"
+)
+
+// Campuses are the values professor.campus accepts (migrations/001_newtables.sql).
+var Campuses = []string{"pilani", "goa", "hyderabad"}
+
+type Config struct {
+	Port string `env:"PORT" default:"4000"`
+
+	SupabaseURL     string `env:"SUPABASE_URL"`
+	SupabaseAnonKey string `env:"SUPABASE_ANON_KEY" secret:"true"`
+	// For the tables and functions only the service role may use, e.g. the
+	// audit log, webhooks and scrubbing a deleted account from review history
+	SupabaseServiceRoleKey string `env:"SUPABASE_SERVICE_ROLE_KEY" secret:"true"`
+
+	// Keys the pseudonyms the audit log keeps instead of emails and IPs,
+	// the same value as grademyprofAuth's
+	AuditKey string `env:"AUDIT_KEY" secret:"true"`
+
+	// Bearer token Prometheus scrapes /metrics with. Unset, /metrics
+	// refuses every request.
+	MetricsToken string `env:"METRICS_TOKEN" secret:"true"`
+
+	// Where grademyprofAuth runs
+	AuthServiceURL string `env:"AUTH_SERVICE_URL" default:"http://localhost:8080"`
+	// How long a token's verification is reused before asking again; 0
+	// asks every time
+	AuthCacheTTL time.Duration `env:"AUTH_CACHE_TTL" default:"10s"`
+
+	// Campus listed when a request doesn't name one
+	DefaultCampus string `env:"DEFAULT_CAMPUS" default:"pilani"`
+
+	// Local dev + Campus + Production
+	CORSOrigins []string `env:"CORS_ORIGINS" default:"http://localhost:5173,http://192.168.2.3,https://kaifn8n.online"`
+
+	// Requests allowed per IP within the window, counted before tokens are
+	// verified; generous, as a campus NAT shares it
+	IPRateLimit  int           `env:"RATE_LIMIT_IP_MAX" default:"600"`
+	IPRateWindow time.Duration `env:"RATE_LIMIT_IP_WINDOW" default:"1m"`
+
+	// Requests allowed per user (per IP before sign-in) within each window
+	GlobalRateLimit        int           `env:"RATE_LIMIT_GLOBAL_MAX" default:"100"`
+	GlobalRateWindow       time.Duration `env:"RATE_LIMIT_GLOBAL_WINDOW" default:"1m"`
+	ReviewCreateRateLimit  int           `env:"RATE_LIMIT_REVIEW_CREATE_MAX" default:"5"`
+	ReviewCreateRateWindow time.Duration `env:"RATE_LIMIT_REVIEW_CREATE_WINDOW" default:"1m"`
+	ReviewUpdateRateLimit  int           `env:"RATE_LIMIT_REVIEW_UPDATE_MAX" default:"10"`
+	ReviewUpdateRateWindow time.Duration `env:"RATE_LIMIT_REVIEW_UPDATE_WINDOW" default:"1m"`
+
+	// Where limiter counters live: "memory" (per replica) or "redis"
+	// (shared, at RedisURL)
+	RateLimitStore string `env:"RATE_LIMIT_STORE" default:"memory"`
+	RedisURL       string `env:"REDIS_URL" secret:"true"`
+
+	// How many virtual reviews at the department mean each professor starts
+	// with, see ranking.go
+	RatingPriorWeight float64 `env:"RATING_PRIOR_WEIGHT" default:"10"`
+
+	// Abuse detection, see abuse.go. Reviews from accounts younger than
+	// AbuseNewAccountAge are flagged; a professor getting at least
+	// AbuseBurstMinReviews reviews, and AbuseBurstFactor times their usual
+	// rate, within AbuseBurstWindow is a burst; comments sharing
+	// AbuseDuplicateSimilarity of their word shingles with any review are
+	// near-duplicates, and are flagged or rejected per AbuseDuplicateAction.
+	AbuseNewAccountAge       time.Duration `env:"ABUSE_NEW_ACCOUNT_AGE" default:"72h"`
+	AbuseBurstWindow         time.Duration `env:"ABUSE_BURST_WINDOW" default:"24h"`
+	AbuseBurstMinReviews     int           `env:"ABUSE_BURST_MIN_REVIEWS" default:"5"`
+	AbuseBurstFactor         float64       `env:"ABUSE_BURST_FACTOR" default:"5"`
+	AbuseDuplicateSimilarity float64       `env:"ABUSE_DUPLICATE_SIMILARITY" default:"0.8"`
+	AbuseDuplicateAction     string        `env:"ABUSE_DUPLICATE_ACTION" default:"flag"`
+
+	// Deleted reviews can be restored for ReviewRestoreWindow; every
+	// ReviewPurgeInterval the older ones are removed for good, see deletion.go.
+	ReviewRestoreWindow time.Duration `env:"REVIEW_RESTORE_WINDOW" default:"168h"`
+	ReviewPurgeInterval time.Duration `env:"REVIEW_PURGE_INTERVAL" default:"1h"`
+
+	// What DELETE /api/me does with the user's reviews: "delete" them or
+	// "anonymize" them, keeping the text for other students, see account.go
+	AccountDeletionReviews string `env:"ACCOUNT_DELETION_REVIEWS" default:"delete"`
+
+	// Webhook deliveries time out after WebhookTimeout. A failed one is
+	// retried WebhookRetryBase later, then twice as long each time, until
+	// it has been tried WebhookMaxAttempts times, see webhooks.go.
+	WebhookTimeout     time.Duration `env:"WEBHOOK_TIMEOUT" default:"10s"`
+	WebhookRetryBase   time.Duration `env:"WEBHOOK_RETRY_BASE" default:"30s"`
+	WebhookMaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" default:"10"`
+	// Lets webhooks point at private, loopback and link-local addresses,
+	// for trying them out locally (cmd/webhook-receiver). Never in production.
+	WebhookAllowPrivate bool `env:"WEBHOOK_ALLOW_PRIVATE" default:"false"`
+
+	CacheSize int           `env:"CACHE_SIZE" default:"1000"`
+	CacheTTL  time.Duration `env:"CACHE_TTL" default:"2m"`
+
+	// Browsers always revalidate (cheap with ETags) so a student sees their
+	// own review right after posting it; a CDN may serve a response for
+	// HTTPSharedMaxAge and keep serving it while it refetches.
+	HTTPMaxAge       time.Duration `env:"HTTP_MAX_AGE" default:"0s"`
+	HTTPSharedMaxAge time.Duration `env:"HTTP_S_MAXAGE" default:"30s"`
+
+	// How long in-flight requests get to finish after SIGTERM, then how long
+	// queued stats recomputations get. Railway waits drainingSeconds
+	// (railway.json) before it kills the process, so keep the sum below it.
+	ShutdownTimeout   time.Duration `env:"SHUTDOWN_TIMEOUT" default:"10s"`
+	StatsDrainTimeout time.Duration `env:"STATS_DRAIN_TIMEOUT" default:"15s"`
+
+	LogLevel string `env:"LOG_LEVEL" default:"info"`
+}
+
+// Load reads the configuration from the environment and, when path is set,
+// from a .env-style file, then validates it. The error lists every problem.
+func Load(path string) (*Config, error) {
+	file := map[string]string{}
+	if path != "" {
+		read, err := godotenv.Read(path)
+		if err != nil {
+			return nil, err
+		}
+		file = read
+	}
+
+	cfg := &Config{}
+	errs := envconfig.Parse(cfg, file).Merge(cfg.validate())
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	cfg.AuthServiceURL = strings.TrimSuffix(cfg.AuthServiceURL, "/")
+	return cfg, nil
+}
+
+func (c *Config) validate() envconfig.Errors {
+	var errs envconfig.Errors
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs.Addf("PORT: must be a port number, got %q", c.Port)
+	}
+	if c.SupabaseURL == "" {
+		errs.Addf("SUPABASE_URL: required")
+	} else if !isHTTPURL(c.SupabaseURL) {
+		errs.Addf("SUPABASE_URL: must be an http(s) URL, got %q", c.SupabaseURL)
+	}
+	if c.SupabaseAnonKey == "" {
+		errs.Addf("SUPABASE_ANON_KEY: required")
+	}
+	if c.SupabaseServiceRoleKey == "" {
+		errs.Addf("SUPABASE_SERVICE_ROLE_KEY: required")
+	}
+	if c.AuditKey == "" {
+		errs.Addf("AUDIT_KEY: required")
+	}
+	if !isHTTPURL(c.AuthServiceURL) {
+		errs.Addf("AUTH_SERVICE_URL: must be an http(s) URL, got %q", c.AuthServiceURL)
+	}
+	if c.AuthCacheTTL < 0 {
+		errs.Addf("AUTH_CACHE_TTL: must not be negative, got %s", c.AuthCacheTTL)
+	}
+	if !slices.Contains(Campuses, c.DefaultCampus) {
+		errs.Addf("DEFAULT_CAMPUS: must be one of %s, got %q", strings.Join(Campuses, ", "), c.DefaultCampus)
+	}
+	if len(c.CORSOrigins) == 0 {
+		errs.Addf("CORS_ORIGINS: at least one origin is required")
+	}
+
+	for _, limit := range []struct {
+		name   string
+		max    int
+		window time.Duration
+	}{
+		{"RATE_LIMIT_IP", c.IPRateLimit, c.IPRateWindow},
+		{"RATE_LIMIT_GLOBAL", c.GlobalRateLimit, c.GlobalRateWindow},
+		{"RATE_LIMIT_REVIEW_CREATE", c.ReviewCreateRateLimit, c.ReviewCreateRateWindow},
+		{"RATE_LIMIT_REVIEW_UPDATE", c.ReviewUpdateRateLimit, c.ReviewUpdateRateWindow},
+	} {
+		if limit.max <= 0 {
+			errs.Addf("%s_MAX: must be positive, got %d", limit.name, limit.max)
+		}
+		if limit.window <= 0 {
+			errs.Addf("%s_WINDOW: must be positive, got %s", limit.name, limit.window)
+		}
+	}
+
+	switch c.RateLimitStore {
+	case "memory":
+	case "redis":
+		if c.RedisURL == "" {
+			errs.Addf("REDIS_URL: required when RATE_LIMIT_STORE is redis")
+		} else if u, err := url.Parse(c.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+			errs.Addf("REDIS_URL: must be a redis:// or rediss:// URL")
+		}
+	default:
+		errs.Addf("RATE_LIMIT_STORE: must be memory or redis, got %q", c.RateLimitStore)
+	}
+
+	if c.RatingPriorWeight < 0 {
+		errs.Addf("RATING_PRIOR_WEIGHT: must be non-negative, got %g", c.RatingPriorWeight)
+	}
+	if c.AbuseNewAccountAge < 0 {
+		errs.Addf("ABUSE_NEW_ACCOUNT_AGE: must not be negative, got %s", c.AbuseNewAccountAge)
+	}
+	if c.AbuseBurstWindow <= 0 {
+		errs.Addf("ABUSE_BURST_WINDOW: must be positive, got %s", c.AbuseBurstWindow)
+	}
+	if c.AbuseBurstMinReviews <= 0 {
+		errs.Addf("ABUSE_BURST_MIN_REVIEWS: must be positive, got %d", c.AbuseBurstMinReviews)
+	}
+	if c.AbuseBurstFactor < 1 {
+		errs.Addf("ABUSE_BURST_FACTOR: must be at least 1, got %g", c.AbuseBurstFactor)
+	}
+	if c.AbuseDuplicateSimilarity <= 0 || c.AbuseDuplicateSimilarity > 1 {
+		errs.Addf("ABUSE_DUPLICATE_SIMILARITY: must be in (0, 1], got %g", c.AbuseDuplicateSimilarity)
+	}
+	if c.AbuseDuplicateAction != "flag" && c.AbuseDuplicateAction != "reject" {
+		errs.Addf("ABUSE_DUPLICATE_ACTION: must be flag or reject, got %q", c.AbuseDuplicateAction)
+	}
+	if c.ReviewRestoreWindow <= 0 {
+		errs.Addf("REVIEW_RESTORE_WINDOW: must be positive, got %s", c.ReviewRestoreWindow)
+	}
+	if c.ReviewPurgeInterval <= 0 {
+		errs.Addf("REVIEW_PURGE_INTERVAL: must be positive, got %s", c.ReviewPurgeInterval)
+	}
+	if c.AccountDeletionReviews != "delete" && c.AccountDeletionReviews != "anonymize" {
+		errs.Addf("ACCOUNT_DELETION_REVIEWS: must be delete or anonymize, got %q", c.AccountDeletionReviews)
+	}
+	if c.WebhookTimeout <= 0 {
+		errs.Addf("WEBHOOK_TIMEOUT: must be positive, got %s", c.WebhookTimeout)
+	}
+	if c.WebhookRetryBase <= 0 {
+		errs.Addf("WEBHOOK_RETRY_BASE: must be positive, got %s", c.WebhookRetryBase)
+	}
+	if c.WebhookMaxAttempts <= 0 {
+		errs.Addf("WEBHOOK_MAX_ATTEMPTS: must be positive, got %d", c.WebhookMaxAttempts)
+	}
+	if c.CacheSize <= 0 {
+		errs.Addf("CACHE_SIZE: must be positive, got %d", c.CacheSize)
+	}
+	if c.CacheTTL <= 0 {
+		errs.Addf("CACHE_TTL: must be positive, got %s", c.CacheTTL)
+	}
+	if c.HTTPMaxAge < 0 {
+		errs.Addf("HTTP_MAX_AGE: must not be negative, got %s", c.HTTPMaxAge)
+	}
+	if c.HTTPSharedMaxAge < 0 {
+		errs.Addf("HTTP_S_MAXAGE: must not be negative, got %s", c.HTTPSharedMaxAge)
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs.Addf("SHUTDOWN_TIMEOUT: must be positive, got %s", c.ShutdownTimeout)
+	}
+	if c.StatsDrainTimeout <= 0 {
+		errs.Addf("STATS_DRAIN_TIMEOUT: must be positive, got %s", c.StatsDrainTimeout)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs.Addf("LOG_LEVEL: must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	return errs
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
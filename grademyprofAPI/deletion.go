@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/fiberkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Deleting a review only sets reviews.deleted_at
+// (migrations/010_review_soft_delete.sql): the review leaves listings and
+// stats, and its author can restore it for REVIEW_RESTORE_WINDOW. After
+// that purgeDeletedReviews removes it, with its edit history.
+
+// notDeleted filters soft-deleted reviews out of a reviews query.
+const notDeleted = "deleted_at=is.null"
+
+// restoreDeadline is when a review deleted at deletedAt can no longer be
+// restored.
+func restoreDeadline(deletedAt time.Time) time.Time {
+	return deletedAt.Add(cfg.ReviewRestoreWindow)
+}
+
+// restoreReview undeletes one of the caller's reviews within the restore
+// window.
+func restoreReview(c *fiber.Ctx) error {
+	reviewID, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return problem.BadRequest(problem.CodeInvalidParameter, "Review ID must be a number")
+	}
+	userEmail, _ := c.Locals("user_email").(string)
+
+	var reviews []Review
+	path := fmt.Sprintf("reviews?id=eq.%d&user_email=eq.%s&select=id,professor_id,deleted_at", reviewID, url.QueryEscape(userEmail))
+	if err := supabase.get(c.UserContext(), "find_review", path, &reviews); err != nil {
+		return problem.Upstream("Failed to restore review")
+	}
+	if len(reviews) == 0 {
+		return problem.NotFound("Review not found or you don't have permission to restore it")
+	}
+	if reviews[0].DeletedAt == "" {
+		return problem.New(fiber.StatusConflict, problem.CodeConflict, "The review is not deleted")
+	}
+	deletedAt, err := time.Parse(time.RFC3339Nano, reviews[0].DeletedAt)
+	if err != nil || time.Now().After(restoreDeadline(deletedAt)) {
+		return problem.New(fiber.StatusGone, problem.CodeRestoreExpired, "The review can no longer be restored")
+	}
+
+	var restored []Review
+	path = fmt.Sprintf("reviews?id=eq.%d&deleted_at=not.is.null", reviewID)
+	if err := supabase.patch(c.UserContext(), "restore_review", path, fiber.Map{"deleted_at": nil}, &restored); err != nil {
+		// Only one live review per professor and user
+		if errors.Is(err, errConflict) {
+			return problem.New(fiber.StatusConflict, problem.CodeConflict, "You have reviewed this professor again since deleting this review")
+		}
+		return problem.Upstream("Failed to restore review")
+	}
+	if len(restored) == 0 {
+		return problem.New(fiber.StatusGone, problem.CodeRestoreExpired, "The review can no longer be restored")
+	}
+
+	entry := fiberkit.AuditEntry(c, audit.ActionReviewRestore, "review", strconv.Itoa(reviewID))
+	entry.Before = audit.Snapshot(fiber.Map{"deleted_at": reviews[0].DeletedAt})
+	entry.After = audit.Snapshot(restored[0])
+	recordAudit(c.UserContext(), entry)
+	emitReviewChange(c.UserContext(), &reviews[0], &restored[0])
+
+	professorID := strconv.Itoa(restored[0].ProfessorID)
+	invalidateCache("reviews:" + professorID)
+	scheduleStatsUpdate(c.UserContext(), professorID)
+
+	return c.JSON(restored[0])
+}
+
+// purgeDeletedReviews removes reviews deleted longer than the restore
+// window ago, now and every REVIEW_PURGE_INTERVAL until ctx is done. Every
+// replica may run it, the deletes are idempotent.
+func purgeDeletedReviews(ctx context.Context) {
+	ticker := time.NewTicker(cfg.ReviewPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		// A purge under way is finished, and audited, at shutdown
+		step := context.WithoutCancel(ctx)
+		cutoff := time.Now().Add(-cfg.ReviewRestoreWindow).UTC().Format(time.RFC3339Nano)
+		var purged []Review
+		if err := supabase.remove(step, "purge_deleted_reviews", "reviews?deleted_at=lt."+cutoff+"&select=id", &purged); err != nil {
+			slog.WarnContext(ctx, "purging deleted reviews failed", "err", err)
+		} else if len(purged) > 0 {
+			reviewsPurged.Add(float64(len(purged)))
+			slog.InfoContext(ctx, "purged deleted reviews", "reviews", len(purged))
+			recordAudit(step, audit.Entry{
+				Actor:      audit.SystemActor,
+				Action:     audit.ActionReviewPurge,
+				TargetType: "review",
+				Before:     audit.Snapshot(fiber.Map{"review_ids": reviewIDs(purged), "deleted_before": cutoff}),
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/Koifish2004/ProfessorWeb/fingerprint"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Every review stores its comment's MinHash signature and band keys
+// (migrations/008_comment_fingerprints.sql), so a new comment is compared
+// against every professor's reviews with one indexed lookup instead of a
+// scan. Comments too short to fingerprint store empty arrays; NULL means
+// not computed yet, see backfillFingerprints.
+
+// maxDuplicateCandidates caps how many band matches are compared exactly.
+const maxDuplicateCandidates = 50
+
+// duplicateMatch is an existing review whose comment is a near-duplicate.
+type duplicateMatch struct {
+	ReviewID    int     `json:"review_id"`
+	ProfessorID int     `json:"professor_id"`
+	Similarity  float64 `json:"similarity"`
+}
+
+type fingerprintedReview struct {
+	ID          int    `json:"id"`
+	ProfessorID int    `json:"professor_id"`
+	Comment     string `json:"comment"`
+}
+
+// fingerprintColumns are the reviews columns to write for a comment with
+// signature sig.
+func fingerprintColumns(sig fingerprint.Signature) map[string]interface{} {
+	if sig == nil {
+		return map[string]interface{}{"comment_minhash": []uint32{}, "comment_bands": []string{}}
+	}
+	return map[string]interface{}{"comment_minhash": sig, "comment_bands": sig.BandKeys()}
+}
+
+// findNearDuplicate returns the review, other than excludeID, whose comment
+// is most similar to comment (signed sig), if that's at least
+// ABUSE_DUPLICATE_SIMILARITY. Every band match is compared by the exact
+// Jaccard similarity of the shingles: the MinHash estimate strays either
+// side of it, and would miss matches just above the threshold. The newest
+// candidates are compared when there are more than
+// maxDuplicateCandidates, since a brigade copies recent reviews.
+func findNearDuplicate(ctx context.Context, comment string, sig fingerprint.Signature, excludeID int) (*duplicateMatch, error) {
+	if sig == nil {
+		return nil, nil
+	}
+
+	var candidates []fingerprintedReview
+	path := fmt.Sprintf("reviews?comment_bands=ov.{%s}&id=neq.%d&%s&select=id,professor_id,comment&order=created_at.desc&limit=%d",
+		strings.Join(sig.BandKeys(), ","), excludeID, notDeleted, maxDuplicateCandidates)
+	if err := supabase.get(ctx, "find_duplicate_comments", path, &candidates); err != nil {
+		return nil, err
+	}
+
+	shingles := fingerprint.Shingles(comment)
+	var best *duplicateMatch
+	for _, candidate := range candidates {
+		similarity := fingerprint.Jaccard(shingles, fingerprint.Shingles(candidate.Comment))
+		if similarity >= cfg.AbuseDuplicateSimilarity && (best == nil || similarity > best.Similarity) {
+			best = &duplicateMatch{ReviewID: candidate.ID, ProfessorID: candidate.ProfessorID, Similarity: similarity}
+		}
+	}
+	return best, nil
+}
+
+// duplicateCommentProblem rejects a review copying another one
+// (ABUSE_DUPLICATE_ACTION=reject).
+func duplicateCommentProblem(match *duplicateMatch) *problem.Problem {
+	return problem.New(fiber.StatusConflict, problem.CodeDuplicateComment, "This comment closely matches an existing review").
+		WithDetails(fiber.Map{"review_id": match.ReviewID, "professor_id": match.ProfessorID, "similarity": match.Similarity})
+}
+
+// backfillFingerprints fingerprints reviews written before fingerprints
+// existed, a page at a time. It runs in the background at startup, and
+// stops early when ctx is done; every replica may run it, the writes are
+// idempotent.
+func backfillFingerprints(ctx context.Context) {
+	const pageSize = 200
+	filled := 0
+	for ctx.Err() == nil {
+		// A page under way is finished at shutdown
+		step := context.WithoutCancel(ctx)
+		var page []Review
+		path := fmt.Sprintf("reviews?comment_minhash=is.null&select=id,comment&order=id.asc&limit=%d", pageSize)
+		if err := supabase.get(step, "list_unfingerprinted_reviews", path, &page); err != nil {
+			slog.WarnContext(ctx, "fingerprint backfill stopped", "filled", filled, "err", err)
+			return
+		}
+
+		for _, review := range page {
+			var updated []Review
+			columns := fingerprintColumns(fingerprint.Sign(review.Comment))
+			if err := supabase.patch(step, "fingerprint_review", fmt.Sprintf("reviews?id=eq.%d", review.ID), columns, &updated); err != nil {
+				slog.WarnContext(ctx, "fingerprint backfill stopped", "filled", filled, "err", err)
+				return
+			}
+			filled++
+		}
+
+		if len(page) < pageSize {
+			if filled > 0 {
+				slog.InfoContext(ctx, "fingerprinted existing reviews", "reviews", filled)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package fiberkit
+
+import (
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuditEntry starts the entry for an action the signed-in user takes on a
+// target in request c. Mount AuthMiddleware first, or the actor is empty.
+// Pass account targets through audit.Pseudonym.
+func AuditEntry(c *fiber.Ctx, action audit.Action, targetType, targetID string) audit.Entry {
+	actor, _ := c.Locals("user_email").(string)
+	role, _ := c.Locals("user_role").(string)
+	requestID, _ := c.Locals("requestid").(string)
+	return audit.Entry{
+		Actor:      audit.Pseudonym(actor),
+		ActorRole:  role,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  requestID,
+		IP:         audit.Pseudonym(c.IP()),
+	}
+}
@@ -0,0 +1,45 @@
+package fiberkit
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/logging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Logging runs after requestid.New. It stores the request ID in the
+// request's user context, so slog calls and outgoing requests made with
+// c.UserContext() carry it, and writes one access log record per request.
+func Logging(c *fiber.Ctx) error {
+	start := time.Now()
+
+	// Copy: the ID may point into fasthttp's buffers, and background work
+	// keeps the context after the request is done
+	id, _ := c.Locals("requestid").(string)
+	ctx := logging.WithRequestID(c.UserContext(), utils.CopyString(id))
+	c.SetUserContext(ctx)
+
+	// Render errors now so their status is what gets logged
+	if err := c.Next(); err != nil {
+		if err := c.App().ErrorHandler(c, err); err != nil {
+			return err
+		}
+	}
+
+	status := c.Response().StatusCode()
+	level := slog.LevelInfo
+	if status >= fiber.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	slog.LogAttrs(ctx, level, "request",
+		slog.String("method", c.Method()),
+		slog.String("path", c.Path()),
+		slog.Int("status", status),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("ip", c.IP()),
+	)
+	return nil
+}
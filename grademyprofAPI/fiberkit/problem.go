@@ -0,0 +1,36 @@
+// Package fiberkit plugs the shared grademyprofShared packages (problem,
+// logging, tracing, ratelimit, audit) into Fiber.
+package fiberkit
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorHandler is the Fiber ErrorHandler: every error a handler or
+// middleware returns is rendered as a Problem. Errors that aren't already
+// a *Problem are logged and hidden behind a generic message.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var p *problem.Problem
+	if !errors.As(err, &p) {
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			p = problem.FromStatus(fe.Code, fe.Message)
+		} else {
+			slog.ErrorContext(c.UserContext(), "unhandled error", "method", c.Method(), "path", c.Path(), "err", err)
+			p = problem.Internal("Internal server error")
+		}
+	}
+
+	// Copy so a shared *Problem value is never mutated per request
+	rendered := *p
+	rendered.Instance = c.Path()
+	if id, ok := c.Locals("requestid").(string); ok {
+		rendered.RequestID = id
+	}
+
+	return c.Status(rendered.Status).JSON(rendered, problem.ContentType)
+}
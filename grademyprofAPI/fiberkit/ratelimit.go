@@ -0,0 +1,35 @@
+package fiberkit
+
+import (
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimit counts each request against l, sets the RateLimit-* headers
+// (and Retry-After on rejections) and hands rejected ones to reject.
+// Requests are keyed by the user the auth middleware verified, so mount it
+// after middleware.IdentifyUser or AuthMiddleware; anywhere else it keys by
+// client IP.
+func RateLimit(l *ratelimit.Limiter, reject func(c *fiber.Ctx, res ratelimit.Result) error) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		res := l.Allow(c.UserContext(), RateLimitIdentity(c))
+		if res.Tighter(c.GetRespHeader(ratelimit.HeaderRemaining)) || !res.Allowed {
+			for name, value := range res.Headers() {
+				c.Set(name, value)
+			}
+		}
+		if !res.Allowed {
+			return reject(c, res)
+		}
+		return c.Next()
+	}
+}
+
+// RateLimitIdentity is the verified user if there is one, the client IP
+// otherwise.
+func RateLimitIdentity(c *fiber.Ctx) string {
+	if email, ok := c.Locals("user_email").(string); ok && email != "" {
+		return ratelimit.UserKey(email)
+	}
+	return ratelimit.IPKey(c.IP())
+}
@@ -0,0 +1,68 @@
+package fiberkit
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Koifish2004/ProfessorWeb/fiberkit")
+
+// Tracing starts a server span for each request, continuing the caller's
+// trace when it sent traceparent, and stores it in the request's user
+// context. Spans are named after the route template, not the raw path.
+func Tracing(c *fiber.Ctx) error {
+	propagator := otel.GetTextMapPropagator()
+	carrier := propagation.HeaderCarrier{}
+	for _, field := range propagator.Fields() {
+		if value := c.Get(field); value != "" {
+			carrier.Set(field, utils.CopyString(value))
+		}
+	}
+
+	// Spans are exported after the request, so nothing may point into
+	// fasthttp's reused buffers
+	method := utils.CopyString(c.Method())
+	ctx := propagator.Extract(c.UserContext(), carrier)
+	ctx, span := tracer.Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(utils.CopyString(c.Path())),
+		),
+	)
+	defer span.End()
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	route := c.Route().Path
+	var fe *fiber.Error
+	if errors.As(err, &fe) && fe.Code == fiber.StatusNotFound {
+		route = "unmatched"
+	}
+
+	// Render errors now so their status is what gets recorded
+	if err != nil {
+		if err := c.App().ErrorHandler(c, err); err != nil {
+			return err
+		}
+	}
+
+	status := c.Response().StatusCode()
+	span.SetName(method + " " + route)
+	span.SetAttributes(
+		semconv.HTTPRoute(route),
+		semconv.HTTPResponseStatusCode(status),
+	)
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, "")
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package fingerprint
+
+import (
+	"math"
+	"testing"
+)
+
+const comment = "Explains proofs slowly and answers every question in office hours"
+
+func TestShingles(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"too short", "great prof", 0},
+		{"minimum length", "one two three four five", 3},
+		{"case and punctuation don't count", "One, two; THREE four five!", 3},
+		{"repeats count once", "a b c a b c a b c", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(Shingles(tt.text)); got != tt.want {
+				t.Errorf("len(Shingles(%q)) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"identical", comment, comment, 1},
+		{"only case differs", comment, "EXPLAINS PROOFS SLOWLY AND ANSWERS EVERY QUESTION IN OFFICE HOURS", 1},
+		{"nothing shared", "one two three four five", "six seven eight nine ten", 0},
+		// 3 shingles each, 2 of them shared: 2 / (3+3-2)
+		{"partly shared", "one two three four five", "one two three four six", 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Jaccard(Shingles(tt.a), Shingles(tt.b)); got != tt.want {
+				t.Errorf("Jaccard = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignatureSimilarity(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		delta float64
+	}{
+		{"identical", comment, comment, 0},
+		{"one word changed", comment, "Explains proofs slowly and answers every question in office time", 0.15},
+		{"unrelated", comment, "Tough grader but the labs are well organised and worth the effort", 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exact := Jaccard(Shingles(tt.a), Shingles(tt.b))
+			estimate := Sign(tt.a).Similarity(Sign(tt.b))
+			if math.Abs(estimate-exact) > tt.delta {
+				t.Errorf("estimate %g is more than %g off the exact similarity %g", estimate, tt.delta, exact)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	if sig := Sign("great prof"); sig != nil {
+		t.Errorf("Sign of a short text = %v, want nil", sig)
+	}
+	sig := Sign(comment)
+	if len(sig) != Size {
+		t.Fatalf("len(Sign) = %d, want %d", len(sig), Size)
+	}
+	if sig.Similarity(Sign(comment)) != 1 {
+		t.Error("signing the same text twice gave different signatures")
+	}
+	if (Signature{1, 2}).Similarity(sig) != 0 {
+		t.Error("a malformed signature was compared")
+	}
+}
+
+func TestBandKeys(t *testing.T) {
+	keys := Sign(comment).BandKeys()
+	if len(keys) != Bands {
+		t.Fatalf("len(BandKeys) = %d, want %d", len(keys), Bands)
+	}
+
+	// A near-duplicate shares a band, an unrelated text none
+	shared := func(text string) int {
+		n := 0
+		for i, key := range Sign(text).BandKeys() {
+			if key == keys[i] {
+				n++
+			}
+		}
+		return n
+	}
+	if shared("Explains proofs slowly and answers every question in office time") == 0 {
+		t.Error("a near-duplicate shares no band")
+	}
+	if n := shared("Tough grader but the labs are well organised and worth the effort"); n != 0 {
+		t.Errorf("an unrelated text shares %d bands", n)
+	}
+	if (Signature{1, 2}).BandKeys() != nil {
+		t.Error("a malformed signature has band keys")
+	}
+}
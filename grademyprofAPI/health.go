@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// readinessTimeout bounds each dependency check so a hung dependency
+// fails the probe instead of hanging it.
+const readinessTimeout = 2 * time.Second
+
+// readinessCacheTTL is how long check results are reused, so /readyz,
+// which anyone can call, doesn't reach Supabase and grademyprofAuth on
+// every request.
+const readinessCacheTTL = 5 * time.Second
+
+// readiness holds the latest check results. Callers arriving while the
+// checks run wait for them rather than running their own.
+var readiness struct {
+	mu      sync.Mutex
+	checked time.Time
+	results map[string]checkResult
+}
+
+type dependencyCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+var readinessChecks = []dependencyCheck{
+	{Name: "supabase", Check: checkSupabase},
+	{Name: "auth_service", Check: checkAuthService},
+}
+
+// getHealthz is the liveness probe: the process is up and serving.
+func getHealthz(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// getReadyz is the readiness probe: every dependency needed to serve
+// reviews answers. It returns 503 with per-dependency results otherwise.
+func getReadyz(c *fiber.Ctx) error {
+	results := cachedChecks(c.UserContext())
+
+	status, code := "ready", fiber.StatusOK
+	for _, result := range results {
+		if result.Status != "ok" {
+			status, code = "not_ready", fiber.StatusServiceUnavailable
+		}
+	}
+
+	return c.Status(code).JSON(fiber.Map{
+		"status": status,
+		"checks": results,
+	})
+}
+
+// cachedChecks returns readinessChecks' results from the last
+// readinessCacheTTL, running them again when they're older.
+func cachedChecks(ctx context.Context) map[string]checkResult {
+	readiness.mu.Lock()
+	defer readiness.mu.Unlock()
+	if readiness.results == nil || time.Since(readiness.checked) >= readinessCacheTTL {
+		// The results are everyone's: a caller hanging up mustn't fail them
+		readiness.results = runChecks(context.WithoutCancel(ctx), readinessChecks)
+		readiness.checked = time.Now()
+	}
+	return readiness.results
+}
+
+// runChecks runs every check concurrently, each with its own timeout.
+// Failures are logged; the results only say a dependency is unavailable,
+// as /readyz is public.
+func runChecks(ctx context.Context, checks []dependencyCheck) map[string]checkResult {
+	results := make(map[string]checkResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check dependencyCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			result := checkResult{
+				Status:    "ok",
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				slog.WarnContext(ctx, "readiness check failed", "check", check.Name, "err", err)
+				result.Status = "error"
+				result.Error = "unavailable"
+			}
+
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}(check)
+	}
+
+	wg.Wait()
+	return results
+}
+
+func checkSupabase(ctx context.Context) error {
+	url := fmt.Sprintf("%s/rest/v1/professor?select=id&limit=1", supabase.URL)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", supabase.APIKey)
+	req.Header.Set("Authorization", "Bearer "+supabase.APIKey)
+	return expectOK(req)
+}
+
+func checkAuthService(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", middleware.AuthServiceURL+"/healthz", nil)
+	if err != nil {
+		return err
+	}
+	return expectOK(req)
+}
+
+func expectOK(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Every version of a review is kept in review_revisions
+// (migrations/009_review_revisions.sql): revision 1 is the review as first
+// posted, and each edit adds the next one. Reviews carry updated_at and
+// edit_count, so clients can show "edited" without fetching the history.
+// Only the service role may read or add revisions
+// (migrations/021_review_revisions_service_only.sql): they hold editor
+// emails and every earlier comment, and moderators see them through
+// getReviewHistory.
+
+// ReviewRevision is what a review said from EditedAt until the next
+// revision, and who wrote it.
+type ReviewRevision struct {
+	ReviewID       int     `json:"review_id"`
+	Revision       int     `json:"revision"`
+	EditorEmail    string  `json:"editor_email"`
+	EditedAt       string  `json:"edited_at"`
+	StudentName    string  `json:"student_name"`
+	Rating         float64 `json:"rating"`
+	Difficulty     float64 `json:"difficulty"`
+	WouldTakeAgain bool    `json:"would_take_again"`
+	Course         string  `json:"course"`
+	Comment        string  `json:"comment"`
+}
+
+// recordRevision stores review, as just written by editor, as its next
+// revision. The review is already saved, so a failure only loses the
+// history entry: it's logged and counted rather than returned.
+func recordRevision(ctx context.Context, review Review, editor string) {
+	editedAt := review.UpdatedAt
+	if editedAt == "" {
+		editedAt = review.CreatedAt
+	}
+
+	revision := ReviewRevision{
+		ReviewID:       review.ID,
+		Revision:       review.EditCount + 1,
+		EditorEmail:    editor,
+		EditedAt:       editedAt,
+		StudentName:    review.StudentName,
+		Rating:         review.Rating,
+		Difficulty:     review.Difficulty,
+		WouldTakeAgain: review.WouldTakeAgain,
+		Course:         review.Course,
+		Comment:        review.Comment,
+	}
+
+	var inserted []ReviewRevision
+	if err := supabaseService.insert(ctx, "record_revision", "review_revisions", revision, &inserted); err != nil {
+		revisionFailures.Inc()
+		slog.ErrorContext(ctx, "review revision not recorded", "review_id", review.ID, "revision", revision.Revision, "err", err)
+	}
+}
+
+// getReviewHistory lists every revision of a review, oldest first.
+func getReviewHistory(c *fiber.Ctx) error {
+	reviewID, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return problem.BadRequest(problem.CodeInvalidParameter, "Review ID must be a number")
+	}
+
+	revisions := []ReviewRevision{}
+	path := fmt.Sprintf("review_revisions?review_id=eq.%d&order=revision.asc", reviewID)
+	if err := supabaseService.get(c.UserContext(), "list_revisions", path, &revisions); err != nil {
+		return problem.Upstream("Failed to fetch review history")
+	}
+
+	if len(revisions) == 0 {
+		var reviews []Review
+		if err := supabase.get(c.UserContext(), "find_review", fmt.Sprintf("reviews?id=eq.%d&select=id", reviewID), &reviews); err != nil {
+			return problem.Upstream("Failed to fetch review history")
+		}
+		if len(reviews) == 0 {
+			return problem.NotFound("Review not found")
+		}
+	}
+
+	return c.JSON(revisions)
+}
@@ -1,20 +1,42 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/Koifish2004/ProfessorWeb/cache"
+	"github.com/Koifish2004/ProfessorWeb/config"
+	"github.com/Koifish2004/ProfessorWeb/fiberkit"
+	"github.com/Koifish2004/ProfessorWeb/fingerprint"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/envconfig"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/logging"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/tracing"
 	"github.com/Koifish2004/ProfessorWeb/middleware"
+	"github.com/Koifish2004/ProfessorWeb/webhook"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/joho/godotenv"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 
@@ -28,12 +50,12 @@ type Professor struct {
 	ReviewCount           int     `json:"review_count"`
 	AverageDifficulty     float64 `json:"average_difficulty"`
 	WouldTakeAgainPercent int     `json:"would_take_again_percent"`
+	BayesianRating        float64 `json:"bayesian_rating"` // confidence-adjusted, see ranking.go
 }
 
 type Review struct {
 	ID             int     `json:"id"`
 	ProfessorID    int     `json:"professor_id"`
-	UserEmail      string  `json:"user_email"`
 	StudentName    string  `json:"student_name"`
 	Rating         float64 `json:"rating"`
 	Difficulty     float64 `json:"difficulty"`
@@ -41,10 +63,20 @@ type Review struct {
 	Course         string  `json:"course"`
 	Comment        string  `json:"comment"`
 	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+	EditCount      int     `json:"edit_count"`
+	DeletedAt      string  `json:"deleted_at,omitempty"`
+
+	// Who wrote it, without their email, see reviewers.go. Reviews from
+	// deleted accounts have no reviewer.
+	ReviewerID   *string `json:"reviewer_id"`
+	ReviewerName string  `json:"reviewer_name"`
+
+	// approved, or held for moderation, see moderation.go
+	ModerationStatus string `json:"moderation_status,omitempty"`
 }
 
 type ReviewInput struct {
-	UserEmail    string  `json:"user_email"`
 	StudentName    string  `json:"student_name"`
 	Rating         float64 `json:"rating"`
 	Difficulty     float64 `json:"difficulty"`
@@ -61,127 +93,177 @@ type SupabaseClient struct {
 
 var supabase *SupabaseClient
 
+// supabaseService uses the service role key, for the tables and functions
+// only the service role may use, e.g. the audit log and webhooks.
+var supabaseService *SupabaseClient
+
+// cfg holds the settings loaded at startup, see config/config.go
+var cfg *config.Config
+
+var tracer = otel.Tracer("github.com/Koifish2004/ProfessorWeb")
+
+var reviewCreateLimiter, reviewUpdateLimiter fiber.Handler
+
 func main() {
+	configPath := flag.String("config", "", "read settings from this .env-style file (environment variables still win)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, secrets redacted, and exit")
+	flag.Parse()
+
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	envErr := godotenv.Load()
+
+	loaded, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
 	}
+	cfg = loaded
 
-	// Initialize Supabase client
-	supabase = &SupabaseClient{
-		URL:    os.Getenv("SUPABASE_URL"),
-		APIKey: os.Getenv("SUPABASE_ANON_KEY"),
+	if *printConfig {
+		if err := envconfig.Print(os.Stdout, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	if supabase.URL == "" || supabase.APIKey == "" {
-		log.Fatal("SUPABASE_URL and SUPABASE_ANON_KEY must be set in .env file")
+	if err := logging.Setup("grademyprofAPI", cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+	if envErr != nil {
+		slog.Info("no .env file found")
 	}
 
-	log.Printf("Connected to Supabase: %s", supabase.URL)
+	shutdownTracing, err := tracing.Setup(context.Background(), "grademyprofAPI")
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 
-	app := fiber.New()
+	// Initialize Supabase client
+	supabase = &SupabaseClient{
+		URL:    cfg.SupabaseURL,
+		APIKey: cfg.SupabaseAnonKey,
+	}
+	supabaseService = &SupabaseClient{
+		URL:    cfg.SupabaseURL,
+		APIKey: cfg.SupabaseServiceRoleKey,
+	}
+	slog.Info("supabase configured")
 
-	// CORS
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "http://localhost:5173,http://192.168.2.3,https://kaifn8n.online", // Local dev + Campus + Production
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, PATCH, DELETE, OPTIONS",
-	}))
+	middleware.AuthServiceURL = cfg.AuthServiceURL
+	middleware.VerificationCacheTTL = cfg.AuthCacheTTL
+	audit.Service = "grademyprofAPI"
+	audit.PseudonymKey = []byte(cfg.AuditKey)
+	responseCache = cache.NewLRU(cfg.CacheSize)
 
+	app, err := newApp()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	app.Use(limiter.New(limiter.Config{
-		Max:100,
-		Expiration: 1*time.Minute,
-		KeyGenerator: func(c*fiber.Ctx) string{
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error{
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "Rate limitted boi, too fast heh",
-			})
-		},
-	}))
+	// SIGINT or SIGTERM stops the background jobs and starts the shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// A second signal kills the process mid-shutdown
+	context.AfterFunc(ctx, stop)
 
+	resumePendingStats(context.Background())
+	goBackground(ctx, backfillFingerprints)
+	goBackground(ctx, purgeDeletedReviews)
+	goBackground(ctx, backfillReviewers)
+	goBackground(ctx, deliverWebhooks)
 
-	reviewCreateLimiter := limiter.New(limiter.Config{
-		Max:5,
-		Expiration: 1*time.Minute,
-		KeyGenerator: func(c*fiber.Ctx) string{
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error{
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "Stop reviewing so much G",
-			})
-		},
-	})
+	slog.Info("server starting", "port", cfg.Port)
+	if err := listenAndServe(ctx, app, ":"+cfg.Port); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	reviewUpdateLimiter := limiter.New(limiter.Config{
-		Max:10,
-		Expiration: 1*time.Minute,
-		KeyGenerator: func(c*fiber.Ctx) string{
-			return c.IP()
-		},
-		LimitReached: func(c *fiber.Ctx) error{
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "Make up your mind cuh, this ain't deep",
-			})
-		},
+// newApp builds the API with every middleware and route, from cfg and the
+// clients main sets up. openapi_test.go checks its routes against
+// openapi.json.
+func newApp() (*fiber.App, error) {
+	app := fiber.New(fiber.Config{
+		ErrorHandler: fiberkit.ErrorHandler,
+		// Params and URLs outlive the request as cache keys and background
+		// stats work, so they must not alias Fiber's reused buffers
+		Immutable: true,
 	})
 
+	app.Use(requestid.New())
+	app.Use(fiberkit.Tracing)
+	app.Use(fiberkit.Logging)
+	app.Use(recordMetrics)
+
+	// Probes and metrics sit ahead of CORS and the rate limiters
+	app.Get("/healthz", getHealthz)
+	app.Get("/readyz", getReadyz)
+	app.Get("/metrics", getMetrics)
 
-	// API routes
-	app.Get("/api/professors", getProfessors)
-	app.Get("/api/professors/:id", getProfessor)
-	app.Get("/api/professors/:id/reviews", getReviews)
-	app.Post("/api/professors/:id/reviews", middleware.AuthMiddleware,reviewCreateLimiter, createReview)
-	app.Patch("/api/professors/:id/reviews/:reviewId", middleware.AuthMiddleware,reviewUpdateLimiter, updateReview)
-	app.Get("/api/professors/:id/user-review",middleware.AuthMiddleware, checkExistingReview)
+	// CORS
+	app.Use(cors.New(cors.Config{
+		AllowOrigins: strings.Join(cfg.CORSOrigins, ","),
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Request-ID",
+		ExposeHeaders: "X-Request-ID, API-Version, Deprecation, Sunset, Link, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After, Content-Disposition",
+		AllowMethods: "GET, POST, PUT, PATCH, DELETE, OPTIONS",
+	}))
+
+	store, err := newRateLimitStore(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up rate limiting: %w", err)
+	}
+
+	// Every limiter keys by user when the request has a valid token:
+	// IdentifyUser verifies it ahead of the global limiter, and the review
+	// limiters sit after AuthMiddleware. The ip limiter runs first and
+	// always keys by IP, so junk tokens can't flood grademyprofAuth
+	app.Use(rateLimit(store, "ip", cfg.IPRateLimit, cfg.IPRateWindow,
+		"Too many requests from your network, try again later"))
+	app.Use(middleware.IdentifyUser)
+	app.Use(rateLimit(store, "global", cfg.GlobalRateLimit, cfg.GlobalRateWindow,
+		"Too many requests, try again later"))
+	reviewCreateLimiter = rateLimit(store, "review_create", cfg.ReviewCreateRateLimit, cfg.ReviewCreateRateWindow,
+		"Too many reviews submitted, try again later")
+	reviewUpdateLimiter = rateLimit(store, "review_update", cfg.ReviewUpdateRateLimit, cfg.ReviewUpdateRateWindow,
+		"Too many review edits, try again later")
+
+	// API routes, see versions.go
+	mountVersions(app)
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"message": "Hello World"})
 	})
-	app.Delete("/api/professors/:id/reviews/:reviewId", middleware.AuthMiddleware, deleteReview)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "4000"
-	}
+	app.Get("/openapi.json", getOpenAPISpec)
+	app.Get("/docs", getDocs)
 
-	log.Printf("Server starting on port %s", port)
-	log.Fatal(app.Listen(":" + port))
+	return app, nil
 }
 
 
 func deleteReview(c *fiber.Ctx) error{
 
-	professorID :=c.Params("id")
 	reviewID := c.Params("reviewId")
 
 	userEmail := c.Locals("user_email")
 	if userEmail == nil{
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Unauthorized",})
+		return problem.Unauthorized(problem.CodeUnauthorized, "Unauthorized")
 	}
 
 	if reviewID == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "Review ID is required"})
+		return problem.BadRequest(problem.CodeInvalidParameter, "Review ID is required")
 	}
 
-	checkURL := fmt.Sprintf("%s/rest/v1/reviews?id=eq.%s&user_email=eq.%s", supabase.URL, reviewID, userEmail)
+	checkURL := fmt.Sprintf("%s/rest/v1/reviews?id=eq.%s&user_email=eq.%s&%s", supabase.URL, url.QueryEscape(reviewID), url.QueryEscape(userEmail.(string)), notDeleted)
 
-	checkReq, err := http.NewRequest("GET", checkURL, nil)
+	checkReq, err := http.NewRequestWithContext(c.UserContext(), "GET", checkURL, nil)
 	if err!=nil {
-		return c.Status(500).JSON(fiber.Map{"error":"Failed to create request"})
+		return problem.Internal("Failed to create request")
 	}
 
 	checkReq.Header.Set("apikey", supabase.APIKey)
 	checkReq.Header.Set("Authorization", "Bearer "+supabase.APIKey)
 
-	client := &http.Client{}
-	checkResp, err :=client.Do(checkReq)
+	checkResp, err := supabase.do("find_review", checkReq)
 	if err != nil{
-		return c.Status(500).JSON(fiber.Map{
-			"error":"Failed to verify review",
-		})
+		return problem.Upstream("Failed to verify review")
 	}
 
 	defer checkResp.Body.Close()
@@ -192,77 +274,91 @@ func deleteReview(c *fiber.Ctx) error{
 	json.Unmarshal(checkBody, &existingReviews)
 
 	if len(existingReviews) == 0{
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Review not found or you don't have permission to delete it",
-		})
+		return problem.NotFound("Review not found or you don't have permission to delete it")
 	}
 
-	deleteURL := fmt.Sprintf("%s/rest/v1/reviews?id=eq.%s", supabase.URL, reviewID)
-
-    deleteReq, err := http.NewRequest("DELETE", deleteURL, nil)
-    if err != nil {
-        return c.Status(500).JSON(fiber.Map{"error": "Failed to create delete request"})
-    }
-
-    deleteReq.Header.Set("apikey", supabase.APIKey)
-    deleteReq.Header.Set("Authorization", "Bearer "+supabase.APIKey)
-
-deleteResp, err := client.Do(deleteReq)
-    if err != nil {
-        return c.Status(500).JSON(fiber.Map{"error": "Failed to delete review"})
-    }
-    defer deleteResp.Body.Close()
+	// Soft delete: the review can be restored until it's purged, see deletion.go
+	deletedAt := time.Now().UTC()
+	var deleted []Review
+	deletePath := fmt.Sprintf("reviews?id=eq.%s&%s", url.QueryEscape(reviewID), notDeleted)
+	if err := supabase.patch(c.UserContext(), "delete_review", deletePath, fiber.Map{"deleted_at": deletedAt.Format(time.RFC3339Nano)}, &deleted); err != nil {
+		return problem.Upstream("Failed to delete review")
+	}
+	if len(deleted) == 0 {
+		return problem.NotFound("Review not found or you don't have permission to delete it")
+	}
 
- if deleteResp.StatusCode != 204 && deleteResp.StatusCode != 200 {
-        deleteBody, _ := io.ReadAll(deleteResp.Body)
-        log.Printf("Supabase API returned status %d: %s", deleteResp.StatusCode, string(deleteBody))
-        return c.Status(500).JSON(fiber.Map{"error": "Failed to delete review"})
-    }
+	entry := fiberkit.AuditEntry(c, audit.ActionReviewDelete, "review", reviewID)
+	entry.Before, entry.After = audit.Snapshot(existingReviews[0]), audit.Snapshot(deleted[0])
+	recordAudit(c.UserContext(), entry)
+	emitReviewChange(c.UserContext(), &existingReviews[0], &deleted[0])
 
-	go updateProfessorStats(professorID)
+	professorID := strconv.Itoa(existingReviews[0].ProfessorID)
+	invalidateCache("reviews:" + professorID)
+	scheduleStatsUpdate(c.UserContext(), professorID)
 
 	return c.JSON(fiber.Map{
         "message": "Review deleted successfully",
+        "restore_until": restoreDeadline(deletedAt),
     })
 }
 
 
 
 func getProfessors(c *fiber.Ctx) error {
-	campus := c.Query("campus", "pilani") 
+	campus := c.Query("campus", cfg.DefaultCampus)
+	sortBy := c.Query("sort", "bayesian")
+
+	if _, ok := professorSorts[sortBy]; !ok {
+		return problem.BadRequest(problem.CodeInvalidParameter, "Invalid sort, use one of bayesian, rating, reviews, name")
+	}
 
 	// request to Supabase
-	url := fmt.Sprintf("%s/rest/v1/professor?campus=eq.%s&order=average_rating.desc", supabase.URL, campus)
+	endpoint := fmt.Sprintf("%s/rest/v1/professor?campus=eq.%s", supabase.URL, url.QueryEscape(campus))
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(c.UserContext(), "GET", endpoint, nil)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create request"})
+		return problem.Internal("Failed to create request")
 	}
 
 	req.Header.Set("apikey", supabase.APIKey)
 	req.Header.Set("Authorization", "Bearer "+supabase.APIKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := supabase.do("list_professors", req)
 	if err != nil {
-		log.Printf("Supabase API error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch professors"})
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to fetch professors")
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to read response"})
+		return problem.Upstream("Failed to read response")
 	}
 
 	if resp.StatusCode != 200 {
-		log.Printf("Supabase API returned status %d: %s", resp.StatusCode, string(body))
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch professors"})
+		slog.ErrorContext(c.UserContext(), "supabase returned an error", "status", resp.StatusCode, "body", string(body))
+		return problem.Upstream("Failed to fetch professors")
 	}
 
 	var professors []Professor
 	if err := json.Unmarshal(body, &professors); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+		return problem.Upstream("Failed to parse response")
+	}
+
+	// The campus list doubles as the peer set for the department priors
+	applyBayesianRatings(professors, professors)
+	if err := sortProfessors(professors, sortBy); err != nil {
+		return problem.Internal("Failed to sort professors")
+	}
+	cacheTags(c, "professors", "campus:"+campus)
+
+	ids := make([]int, len(professors))
+	for i, p := range professors {
+		ids[i] = p.ID
+	}
+	if stamps, err := professorsUpdatedAt(c.UserContext(), ids); err == nil {
+		setLastModified(c, stamps)
 	}
 
 	return c.JSON(professors)
@@ -271,22 +367,19 @@ func getProfessors(c *fiber.Ctx) error {
 
 func checkExistingReview(c *fiber.Ctx) error {
 	professorID := c.Params("id")
-	userEmail := c.Query("user_email")
-
-	if userEmail == "" {
-		return c.Status(400).JSON(fiber.Map{"error":"User email is required"})
-	}
+	// Always the caller's own review: looking up someone else's by email
+	// would tie their pseudonym to it
+	userEmail, _ := c.Locals("user_email").(string)
 
-	url :=fmt.Sprintf("%s/rest/v1/reviews?professor_id=eq.%s&user_email=eq.%s", supabase.URL, professorID, userEmail)
+	checkURL :=fmt.Sprintf("%s/rest/v1/reviews?professor_id=eq.%s&user_email=eq.%s&%s", supabase.URL, url.QueryEscape(professorID), url.QueryEscape(userEmail), notDeleted)
 
-	req, _ :=http.NewRequest("GET", url, nil)
+	req, _ :=http.NewRequestWithContext(c.UserContext(), "GET", checkURL, nil)
 	req.Header.Add("apikey", supabase.APIKey)
 	req.Header.Add("Authorization", "Bearer "+supabase.APIKey)
 
-	client :=&http.Client{}
-	resp, err:= client.Do(req)
+	resp, err := supabase.do("check_existing_review", req)
 	if err!=nil{
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to check exisiting review"})
+		return problem.Upstream("Failed to check exisiting review")
 
 	}
 
@@ -312,41 +405,53 @@ func getProfessor(c *fiber.Ctx) error {
 	id := c.Params("id")
 
 	// Make request to Supabase REST API
-	url := fmt.Sprintf("%s/rest/v1/professor?id=eq.%s", supabase.URL, id)
+	endpoint := fmt.Sprintf("%s/rest/v1/professor?id=eq.%s", supabase.URL, id)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(c.UserContext(), "GET", endpoint, nil)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create request"})
+		return problem.Internal("Failed to create request")
 	}
 
 	req.Header.Set("apikey", supabase.APIKey)
 	req.Header.Set("Authorization", "Bearer "+supabase.APIKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := supabase.do("get_professor", req)
 	if err != nil {
-		log.Printf("Supabase API error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch professor"})
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to fetch professor")
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to read response"})
+		return problem.Upstream("Failed to read response")
 	}
 
 	if resp.StatusCode != 200 {
-		log.Printf("Supabase API returned status %d: %s", resp.StatusCode, string(body))
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch professor"})
+		slog.ErrorContext(c.UserContext(), "supabase returned an error", "status", resp.StatusCode, "body", string(body))
+		return problem.Upstream("Failed to fetch professor")
 	}
 
 	var professors []Professor
 	if err := json.Unmarshal(body, &professors); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+		return problem.Upstream("Failed to parse response")
 	}
 
 	if len(professors) == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "Professor not found"})
+		return problem.NotFound("Professor not found")
+	}
+
+	var peers []Professor
+	peersPath := fmt.Sprintf("professor?campus=eq.%s&select=campus,department,average_rating,review_count", url.QueryEscape(professors[0].Campus))
+	if err := supabase.get(c.UserContext(), "list_campus_peers", peersPath, &peers); err != nil {
+		slog.WarnContext(c.UserContext(), "failed to fetch rating priors", "err", err)
+		peers = professors
+	}
+	applyBayesianRatings(professors, peers)
+	cacheTags(c, "professors", "professor:"+id, "campus:"+professors[0].Campus)
+
+	if stamps, err := professorsUpdatedAt(c.UserContext(), []int{professors[0].ID}); err == nil {
+		setLastModified(c, stamps)
 	}
 
 	return c.JSON(professors[0])
@@ -356,37 +461,43 @@ func getReviews(c *fiber.Ctx) error {
 	professorID := c.Params("id")
 
 	// Make request to Supabase REST API
-	url := fmt.Sprintf("%s/rest/v1/reviews?professor_id=eq.%s&order=created_at.desc", supabase.URL, professorID)
+	url := fmt.Sprintf("%s/rest/v1/reviews?professor_id=eq.%s&%s&order=created_at.desc", supabase.URL, professorID, publicOnly)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(c.UserContext(), "GET", url, nil)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create request"})
+		return problem.Internal("Failed to create request")
 	}
 
 	req.Header.Set("apikey", supabase.APIKey)
 	req.Header.Set("Authorization", "Bearer "+supabase.APIKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := supabase.do("list_reviews", req)
 	if err != nil {
-		log.Printf("Supabase API error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to fetch reviews")
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to read response"})
+		return problem.Upstream("Failed to read response")
 	}
 
 	if resp.StatusCode != 200 {
-		log.Printf("Supabase API returned status %d: %s", resp.StatusCode, string(body))
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reviews"})
+		slog.ErrorContext(c.UserContext(), "supabase returned an error", "status", resp.StatusCode, "body", string(body))
+		return problem.Upstream("Failed to fetch reviews")
 	}
 
 	var reviews []Review
 	if err := json.Unmarshal(body, &reviews); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+		return problem.Upstream("Failed to parse response")
+	}
+
+	cacheTags(c, "reviews:"+professorID)
+	if id, err := strconv.Atoi(professorID); err == nil {
+		if stamps, err := professorsUpdatedAt(c.UserContext(), []int{id}); err == nil {
+			setLastModified(c, stamps)
+		}
 	}
 
 	return c.JSON(reviews)
@@ -397,33 +508,72 @@ func createReview(c *fiber.Ctx) error {
 
 	var reviewInput ReviewInput
 	if err := c.BodyParser(&reviewInput); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		return problem.BadRequest(problem.CodeInvalidBody, "Invalid request body")
+	}
+
+	// Flag suspicious reviews, and hold them out of listings and stats
+	// until a moderator clears them. Without the detector's data the review
+	// goes through: it can still be moderated after the fact.
+	sig := fingerprint.Sign(reviewInput.Comment)
+	accountCreated, _ := c.Locals("account_created").(time.Time)
+	verdict, err := assessReview(c.UserContext(), professorID, reviewInput, sig, accountCreated)
+	if err != nil {
+		slog.WarnContext(c.UserContext(), "abuse detection failed, publishing review", "professor_id", professorID, "err", err)
+	}
+	if verdict.Duplicate != nil && cfg.AbuseDuplicateAction == "reject" {
+		reviewFlags.WithLabelValues(flagNearDuplicate).Inc()
+		return duplicateCommentProblem(verdict.Duplicate)
+	}
+	status := statusApproved
+	if verdict.Hold {
+		status = statusHeld
+		reviewsHeld.Inc()
+	}
+	for _, flag := range verdict.Flags {
+		reviewFlags.WithLabelValues(flag).Inc()
+	}
+	if len(verdict.Flags) > 0 {
+		slog.InfoContext(c.UserContext(), "review flagged", "professor_id", professorID, "flags", verdict.Flags, "held", verdict.Hold)
+	}
+
+	// The review belongs to whoever the token says, and shows their
+	// pseudonym or handle
+	userEmail, _ := c.Locals("user_email").(string)
+	reviewer, err := reviewerFor(c.UserContext(), userEmail)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "reviewer lookup failed", "err", err)
+		return problem.Upstream("Failed to create review")
 	}
 
 	// Create the review data with professor_id
 	reviewData := map[string]interface{}{
-		"professor_id":     professorID,
-		"user_email": 		reviewInput.UserEmail,
-		"student_name":     reviewInput.StudentName,
-		"rating":           reviewInput.Rating,
-		"difficulty":       reviewInput.Difficulty,
-		"would_take_again": reviewInput.WouldTakeAgain,
-		"course":           reviewInput.Course,
-		"comment":          reviewInput.Comment,
-	}
+		"professor_id":      professorID,
+		"user_email":        userEmail,
+		"reviewer_id":       reviewer.ID,
+		"reviewer_name":     reviewer.Name(),
+		"student_name":      reviewInput.StudentName,
+		"rating":            reviewInput.Rating,
+		"difficulty":        reviewInput.Difficulty,
+		"would_take_again":  reviewInput.WouldTakeAgain,
+		"course":            reviewInput.Course,
+		"comment":           reviewInput.Comment,
+		"moderation_status": status,
+		"moderation_flags":  append([]string{}, verdict.Flags...),
+	}
+	maps.Copy(reviewData, fingerprintColumns(sig))
 
 	// Convert to JSON for Supabase
 	jsonData, err := json.Marshal(reviewData)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to process review data"})
+		return problem.Internal("Failed to process review data")
 	}
 
 	// Make request to Supabase REST API
 	url := fmt.Sprintf("%s/rest/v1/reviews", supabase.URL)
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+	req, err := http.NewRequestWithContext(c.UserContext(), "POST", url, strings.NewReader(string(jsonData)))
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create request"})
+		return problem.Internal("Failed to create request")
 	}
 
 	req.Header.Set("apikey", supabase.APIKey)
@@ -431,49 +581,70 @@ func createReview(c *fiber.Ctx) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Prefer", "return=representation")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := supabase.do("create_review", req)
 	if err != nil {
-		log.Printf("Supabase API error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create review"})
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to create review")
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to read response"})
+		return problem.Upstream("Failed to read response")
 	}
 
 	if resp.StatusCode != 201 {
-		log.Printf("Supabase API returned status %d: %s", resp.StatusCode, string(body))
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create review"})
+		slog.ErrorContext(c.UserContext(), "supabase returned an error", "status", resp.StatusCode, "body", string(body))
+		return problem.Upstream("Failed to create review")
 	}
 
 	var createdReview []Review
 	if err := json.Unmarshal(body, &createdReview); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+		return problem.Upstream("Failed to parse response")
 	}
 
 	if len(createdReview) == 0 {
-		return c.Status(500).JSON(fiber.Map{"error": "No review returned"})
+		return problem.Upstream("No review returned")
 	}
 
+	recordRevision(c.UserContext(), createdReview[0], userEmail)
+	emitReviewChange(c.UserContext(), nil, &createdReview[0])
+
 	// Update professor statistics after creating review
-	go updateProfessorStats(professorID)
+	invalidateCache("reviews:" + professorID)
+	scheduleStatsUpdate(c.UserContext(), professorID)
 
 	return c.JSON(createdReview[0])
 }
 
 func updateReview(c *fiber.Ctx) error {
-	professorID := c.Params("id")
-	reviewID := c.Params("reviewId")
+	reviewID, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return problem.BadRequest(problem.CodeInvalidParameter, "Review ID must be a number")
+	}
 
 	var reviewInput ReviewInput
 	if err := c.BodyParser(&reviewInput); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		return problem.BadRequest(problem.CodeInvalidBody, "Invalid request body")
 	}
 
-	log.Printf("🔍 Update Review - Professor: %s, ReviewID: %s, UserEmail: %s", professorID, reviewID, reviewInput.UserEmail)
+	// Only the author can edit a review. The edit count numbers the
+	// revision this edit adds, see history.go; the rest is the audit log's
+	// before snapshot
+	userEmail, _ := c.Locals("user_email").(string)
+	owned := fmt.Sprintf("id=eq.%d&user_email=eq.%s&%s", reviewID, url.QueryEscape(userEmail), notDeleted)
+	var current []Review
+	if err := supabase.get(c.UserContext(), "find_review", "reviews?"+owned, &current); err != nil {
+		return problem.Upstream("Failed to update review")
+	}
+	if len(current) == 0 {
+		return problem.NotFound("Review not found or you don't have permission to edit it")
+	}
+	editCount := current[0].EditCount
+	// The review's own professor, whatever the path said
+	professorID := strconv.Itoa(current[0].ProfessorID)
+
+	slog.DebugContext(c.UserContext(), "updating review", "professor_id", professorID, "review_id", reviewID)
 
 	// Create the review data for update
 	reviewData := map[string]interface{}{
@@ -483,22 +654,41 @@ func updateReview(c *fiber.Ctx) error {
 		"would_take_again": reviewInput.WouldTakeAgain,
 		"course":           reviewInput.Course,
 		"comment":          reviewInput.Comment,
+		"updated_at":       time.Now().UTC().Format(time.RFC3339Nano),
+		"edit_count":       editCount + 1,
 	}
 
+	// An edit can't sneak copied text past the duplicate check
+	sig := fingerprint.Sign(reviewInput.Comment)
+	duplicate, err := findNearDuplicate(c.UserContext(), reviewInput.Comment, sig, reviewID)
+	if err != nil {
+		slog.WarnContext(c.UserContext(), "duplicate check failed, saving edit", "review_id", reviewID, "err", err)
+	}
+	if duplicate != nil {
+		reviewFlags.WithLabelValues(flagNearDuplicate).Inc()
+		if cfg.AbuseDuplicateAction == "reject" {
+			return duplicateCommentProblem(duplicate)
+		}
+		slog.InfoContext(c.UserContext(), "edited review flagged", "review_id", reviewID, "flags", []string{flagNearDuplicate}, "held", true)
+		reviewsHeld.Inc()
+		reviewData["moderation_status"] = statusHeld
+		reviewData["moderation_flags"] = []string{flagNearDuplicate}
+	}
+	maps.Copy(reviewData, fingerprintColumns(sig))
+
 	// Convert to JSON for Supabase
 	jsonData, err := json.Marshal(reviewData)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to process review data"})
+		return problem.Internal("Failed to process review data")
 	}
 
-	// Make PATCH request to Supabase to update the review
-	url := fmt.Sprintf("%s/rest/v1/reviews?id=eq.%s", supabase.URL, reviewID)
-
-	log.Printf("🌐 Supabase PATCH URL: %s", url)
+	// Make PATCH request to Supabase to update the review. Matching the
+	// edit count makes concurrent edits fail instead of sharing a revision.
+	updateURL := fmt.Sprintf("%s/rest/v1/reviews?%s&edit_count=eq.%d", supabase.URL, owned, editCount)
 
-	req, err := http.NewRequest("PATCH", url, strings.NewReader(string(jsonData)))
+	req, err := http.NewRequestWithContext(c.UserContext(), "PATCH", updateURL, strings.NewReader(string(jsonData)))
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create request"})
+		return problem.Internal("Failed to create request")
 	}
 
 	req.Header.Set("apikey", supabase.APIKey)
@@ -506,79 +696,119 @@ func updateReview(c *fiber.Ctx) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Prefer", "return=representation")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := supabase.do("update_review", req)
 	if err != nil {
-		log.Printf("Supabase API error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update review"})
+		slog.ErrorContext(c.UserContext(), "supabase request failed", "err", err)
+		return problem.Upstream("Failed to update review")
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to read response"})
+		return problem.Upstream("Failed to read response")
 	}
 
 	if resp.StatusCode != 200 {
-		log.Printf("Supabase API returned status %d: %s", resp.StatusCode, string(body))
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update review"})
+		slog.ErrorContext(c.UserContext(), "supabase returned an error", "status", resp.StatusCode, "body", string(body))
+		return problem.Upstream("Failed to update review")
 	}
 
 	var updatedReview []Review
 	if err := json.Unmarshal(body, &updatedReview); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse response"})
+		return problem.Upstream("Failed to parse response")
 	}
 
 	if len(updatedReview) == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "Review not found"})
+		return problem.New(fiber.StatusConflict, problem.CodeConflict, "The review was edited at the same time, try again")
 	}
 
+	recordRevision(c.UserContext(), updatedReview[0], userEmail)
+
+	entry := fiberkit.AuditEntry(c, audit.ActionReviewUpdate, "review", strconv.Itoa(reviewID))
+	entry.Before, entry.After = audit.Snapshot(current[0]), audit.Snapshot(updatedReview[0])
+	recordAudit(c.UserContext(), entry)
+	emitReviewChange(c.UserContext(), &current[0], &updatedReview[0])
+
 	// Update professor statistics after updating review
-	go updateProfessorStats(professorID)
+	invalidateCache("reviews:" + professorID)
+	scheduleStatsUpdate(c.UserContext(), professorID)
 
 	return c.JSON(updatedReview[0])
 }
 
-func updateProfessorStats(professorID string) {
-	// Get all reviews for this professor
-	url := fmt.Sprintf("%s/rest/v1/reviews?professor_id=eq.%s", supabase.URL, professorID)
+// scheduleStatsUpdate recomputes a professor's aggregates in the background
+// after a review write. Failures are logged and counted, never surfaced to the
+// request that triggered them. Shutdown waits for the work, see shutdown.go.
+func scheduleStatsUpdate(ctx context.Context, professorID string) {
+	scheduleStatsRecompute(ctx, professorID, nil)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// scheduleStatsRecompute is scheduleStatsUpdate, calling done, when not
+// nil, once the recomputation has succeeded.
+func scheduleStatsRecompute(ctx context.Context, professorID string, done func(context.Context, string)) {
+	// Keep the request ID for logs, but outlive the request. The work gets
+	// its own trace, linked to the request's span.
+	ctx = context.WithoutCancel(ctx)
+	link := trace.LinkFromContext(ctx)
+
+	statsInFlight.Inc()
+	statsWork.Go(professorID, func() {
+		defer statsInFlight.Dec()
+
+		ctx, span := tracer.Start(ctx, "updateProfessorStats",
+			trace.WithNewRoot(),
+			trace.WithLinks(link),
+			trace.WithAttributes(attribute.String("professor.id", professorID)),
+		)
+		defer span.End()
+
+		if err := updateProfessorStats(ctx, professorID); err != nil {
+			statsFailures.Inc()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "stats update failed")
+			slog.ErrorContext(ctx, "stats update failed", "professor_id", professorID, "err", err)
+			return
+		}
+		if done != nil {
+			done(ctx, professorID)
+		}
+	})
+}
+
+func updateProfessorStats(ctx context.Context, professorID string) error {
+	// Get all reviews for this professor that are visible publicly
+	url := fmt.Sprintf("%s/rest/v1/reviews?professor_id=eq.%s&%s", supabase.URL, professorID, publicOnly)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		log.Printf("Failed to create request for stats update: %v", err)
-		return
+		return fmt.Errorf("create stats request: %w", err)
 	}
 
 	req.Header.Set("apikey", supabase.APIKey)
 	req.Header.Set("Authorization", "Bearer "+supabase.APIKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := supabase.do("stats_fetch_reviews", req)
 	if err != nil {
-		log.Printf("Failed to fetch reviews for stats: %v", err)
-		return
+		return fmt.Errorf("fetch reviews for stats: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Failed to read reviews response: %v", err)
-		return
+		return fmt.Errorf("read reviews response: %w", err)
 	}
 
 	if resp.StatusCode != 200 {
-		log.Printf("Failed to fetch reviews for stats, status %d: %s", resp.StatusCode, string(body))
-		return
+		return fmt.Errorf("fetch reviews for stats: status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var reviews []Review
 	if err := json.Unmarshal(body, &reviews); err != nil {
-		log.Printf("Failed to parse reviews for stats: %v", err)
-		return
+		return fmt.Errorf("parse reviews for stats: %w", err)
 	}
 
 	reviewCount := len(reviews)
-	log.Printf("📊 Updating stats for professor %s - Found %d reviews", professorID, reviewCount)
+	slog.InfoContext(ctx, "updating professor stats", "professor_id", professorID, "reviews", reviewCount)
 
 	// If no reviews, reset stats to defaults
 	if reviewCount == 0 {
@@ -589,22 +819,12 @@ func updateProfessorStats(professorID string) {
 			"would_take_again_percent": 0,
 		}
 
-		jsonData, _ := json.Marshal(updateData)
-		updateURL := fmt.Sprintf("%s/rest/v1/professor?id=eq.%s", supabase.URL, professorID)
-		updateReq, _ := http.NewRequest("PATCH", updateURL, strings.NewReader(string(jsonData)))
-		updateReq.Header.Set("apikey", supabase.APIKey)
-		updateReq.Header.Set("Authorization", "Bearer "+supabase.APIKey)
-		updateReq.Header.Set("Content-Type", "application/json")
-
-		updateResp, err := client.Do(updateReq)
-		if err != nil {
-			log.Printf("Failed to reset professor stats: %v", err)
-			return
+		if err := patchProfessorStats(ctx, professorID, updateData); err != nil {
+			return fmt.Errorf("reset professor stats: %w", err)
 		}
-		defer updateResp.Body.Close()
 
-		log.Printf("Reset professor %s stats to 0 (no reviews)", professorID)
-		return
+		slog.InfoContext(ctx, "reset professor stats, no reviews left", "professor_id", professorID)
+		return nil
 	}
 
 	// Calculate new statistics
@@ -632,33 +852,70 @@ func updateProfessorStats(professorID string) {
 		"would_take_again_percent": wouldTakeAgainPercent,
 	}
 
+	if err := patchProfessorStats(ctx, professorID, updateData); err != nil {
+		return fmt.Errorf("update professor stats: %w", err)
+	}
+	return nil
+}
+
+// patchProfessorStats writes the aggregate columns for one professor, drops
+// the cached pages that showed the old numbers and, when the numbers moved,
+// sends professor.stats_changed.
+func patchProfessorStats(ctx context.Context, professorID string, updateData map[string]interface{}) error {
+	var before []ProfessorStats
+	statsPath := "professor?id=eq." + professorID + "&select=average_rating,review_count,average_difficulty,would_take_again_percent"
+	if err := supabase.get(ctx, "stats_fetch_professor", statsPath, &before); err != nil {
+		slog.WarnContext(ctx, "reading old professor stats failed", "professor_id", professorID, "err", err)
+	}
+
 	jsonData, err := json.Marshal(updateData)
 	if err != nil {
-		log.Printf("Failed to marshal update data: %v", err)
-		return
+		return err
 	}
 
 	updateURL := fmt.Sprintf("%s/rest/v1/professor?id=eq.%s", supabase.URL, professorID)
-	updateReq, err := http.NewRequest("PATCH", updateURL, strings.NewReader(string(jsonData)))
+	updateReq, err := http.NewRequestWithContext(ctx, "PATCH", updateURL, strings.NewReader(string(jsonData)))
 	if err != nil {
-		log.Printf("Failed to create update request: %v", err)
-		return
+		return err
 	}
 
 	updateReq.Header.Set("apikey", supabase.APIKey)
 	updateReq.Header.Set("Authorization", "Bearer "+supabase.APIKey)
 	updateReq.Header.Set("Content-Type", "application/json")
+	updateReq.Header.Set("Prefer", "return=representation")
 
-	updateResp, err := client.Do(updateReq)
+	updateResp, err := supabase.do("stats_update_professor", updateReq)
 	if err != nil {
-		log.Printf("Failed to update professor stats: %v", err)
-		return
+		return err
 	}
 	defer updateResp.Body.Close()
 
-	if updateResp.StatusCode != 204 {
-		updateBody, _ := io.ReadAll(updateResp.Body)
-		log.Printf("Failed to update professor stats, status %d: %s", updateResp.StatusCode, string(updateBody))
-		return
+	updateBody, _ := io.ReadAll(updateResp.Body)
+	if updateResp.StatusCode != 200 {
+		return fmt.Errorf("status %d: %s", updateResp.StatusCode, string(updateBody))
+	}
+
+	var updated []Professor
+	json.Unmarshal(updateBody, &updated)
+	invalidateProfessor(professorID, updated)
+
+	if len(updated) > 0 {
+		professor := updated[0]
+		change := ProfessorStatsChange{
+			ProfessorID: professor.ID,
+			After: ProfessorStats{
+				AverageRating:         professor.AverageRating,
+				ReviewCount:           professor.ReviewCount,
+				AverageDifficulty:     professor.AverageDifficulty,
+				WouldTakeAgainPercent: professor.WouldTakeAgainPercent,
+			},
+		}
+		if len(before) > 0 {
+			change.Before = &before[0]
+		}
+		if change.Before == nil || *change.Before != change.After {
+			emitWebhook(ctx, webhook.ProfessorStatsChanged, change)
+		}
 	}
+	return nil
 }
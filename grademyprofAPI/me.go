@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/config"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// campusDomain is the email domain below which each campus has its own
+// subdomain, e.g. f2021@goa.bits-pilani.ac.in.
+const campusDomain = ".bits-pilani.ac.in"
+
+// Me is the signed-in user as the token describes them, with the identity
+// their reviews show (reviewers.go).
+type Me struct {
+	Email          string     `json:"email"`
+	Campus         string     `json:"campus,omitempty"`
+	Role           string     `json:"role"`
+	AccountCreated *time.Time `json:"account_created,omitempty"`
+	Reviewer       *Reviewer  `json:"reviewer,omitempty"`
+}
+
+// MyReview is one of the user's reviews on their dashboard, including
+// held ones and deleted ones they can still restore.
+type MyReview struct {
+	Review
+	ProfessorName       string     `json:"professor_name"`
+	ProfessorDepartment string     `json:"professor_department"`
+	RestoreUntil        *time.Time `json:"restore_until,omitempty"`
+}
+
+// campusFor returns the campus email belongs to, or "" for addresses
+// outside the campus domains.
+func campusFor(email string) string {
+	_, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok {
+		return ""
+	}
+	campus, ok := strings.CutSuffix(domain, campusDomain)
+	if !ok {
+		return ""
+	}
+	if !slices.Contains(config.Campuses, campus) {
+		return ""
+	}
+	return campus
+}
+
+// currentUser describes the signed-in user from their token.
+func currentUser(c *fiber.Ctx) Me {
+	email, _ := c.Locals("user_email").(string)
+	role, _ := c.Locals("user_role").(string)
+	me := Me{Email: email, Campus: campusFor(email), Role: role}
+	if created, ok := c.Locals("account_created").(time.Time); ok {
+		me.AccountCreated = &created
+	}
+	return me
+}
+
+// getMe describes the signed-in user. Their pseudonym is picked here if
+// they don't have one yet, so they see it before posting.
+func getMe(c *fiber.Ctx) error {
+	me := currentUser(c)
+	reviewer, err := reviewerFor(c.UserContext(), me.Email)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "reviewer lookup failed", "err", err)
+		return problem.Upstream("Failed to fetch your profile")
+	}
+	me.Reviewer = &reviewer
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(me)
+}
+
+// getMyReviews lists the signed-in user's reviews, newest first, with the
+// professor each one is about.
+func getMyReviews(c *fiber.Ctx) error {
+	email, _ := c.Locals("user_email").(string)
+
+	var reviews []Review
+	path := fmt.Sprintf("reviews?user_email=eq.%s&order=created_at.desc", url.QueryEscape(email))
+	if err := supabase.get(c.UserContext(), "list_user_reviews", path, &reviews); err != nil {
+		return problem.Upstream("Failed to fetch your reviews")
+	}
+
+	var ids []int
+	for _, review := range reviews {
+		ids = append(ids, review.ProfessorID)
+	}
+	var professors []Professor
+	if len(ids) > 0 {
+		path := fmt.Sprintf("professor?id=in.(%s)&select=id,name,department", joinIDs(ids))
+		if err := supabase.get(c.UserContext(), "list_professors", path, &professors); err != nil {
+			return problem.Upstream("Failed to fetch your reviews")
+		}
+	}
+	byID := make(map[int]Professor, len(professors))
+	for _, professor := range professors {
+		byID[professor.ID] = professor
+	}
+
+	mine := []MyReview{}
+	for _, review := range reviews {
+		item := MyReview{
+			Review:              review,
+			ProfessorName:       byID[review.ProfessorID].Name,
+			ProfessorDepartment: byID[review.ProfessorID].Department,
+		}
+		if review.DeletedAt != "" {
+			deletedAt, err := time.Parse(time.RFC3339Nano, review.DeletedAt)
+			if err != nil || time.Now().After(restoreDeadline(deletedAt)) {
+				// Waiting for the purge job, can't be restored any more
+				continue
+			}
+			deadline := restoreDeadline(deletedAt)
+			item.RestoreUntil = &deadline
+		}
+		mine = append(mine, item)
+	}
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(mine)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "grademyprof_api"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	rateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by each rate limiter.",
+	}, []string{"limiter"})
+
+	supabaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "supabase_request_duration_seconds",
+		Help:      "Latency of Supabase REST calls by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	supabaseErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "supabase_errors_total",
+		Help:      "Supabase REST calls that failed or returned a 4xx/5xx, by operation.",
+	}, []string{"operation"})
+
+	statsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "stats_recompute_in_flight",
+		Help:      "Professor stats recomputations queued or running.",
+	})
+
+	statsFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "stats_recompute_failures_total",
+		Help:      "Professor stats recomputations that failed.",
+	})
+
+	reviewFlags = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "review_flags_total",
+		Help:      "New reviews flagged by the abuse detector, by flag.",
+	}, []string{"flag"})
+
+	reviewsHeld = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reviews_held_total",
+		Help:      "New reviews held for moderation.",
+	})
+
+	revisionFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "review_revision_failures_total",
+		Help:      "Review writes whose revision could not be added to the edit history.",
+	})
+
+	auditFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "audit_failures_total",
+		Help:      "Audit entries that could not be stored and went to the log instead.",
+	})
+
+	webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by outcome: delivered, retried or failed for good.",
+	}, []string{"outcome"})
+
+	webhookDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_delivery_duration_seconds",
+		Help:      "Time webhook receivers took to answer a delivery.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	webhookQueueFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_queue_failures_total",
+		Help:      "Webhook events that could not be queued for delivery.",
+	})
+
+	reviewsPurged = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reviews_purged_total",
+		Help:      "Deleted reviews removed for good after their restore window.",
+	})
+
+	versionRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "api_version_requests_total",
+		Help:      "Requests served per API version, including the unversioned alias.",
+	}, []string{"version"})
+)
+
+// getMetrics serves the Prometheus registry to scrapers with
+// METRICS_TOKEN: the route and error counts are no business of the public.
+func getMetrics(c *fiber.Ctx) error {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return problem.Unauthorized(problem.CodeMissingToken, "Missing metrics token")
+	}
+	if cfg.MetricsToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.MetricsToken)) != 1 {
+		return problem.Unauthorized(problem.CodeInvalidToken, "Invalid metrics token")
+	}
+	return serveMetrics(c)
+}
+
+var serveMetrics = adaptor.HTTPHandler(promhttp.Handler())
+
+// recordMetrics times every request and labels it with the route template
+// (e.g. /api/v1/professors/:id) so IDs don't explode label cardinality.
+func recordMetrics(c *fiber.Ctx) error {
+	start := time.Now()
+
+	err := c.Next()
+	route := c.Route().Path
+
+	// Fiber reports "no route matched" as a plain *fiber.Error 404
+	var fe *fiber.Error
+	if errors.As(err, &fe) && fe.Code == fiber.StatusNotFound {
+		route = "unmatched"
+	}
+
+	// Render errors now so their status is what gets recorded
+	if err != nil {
+		if err := c.App().ErrorHandler(c, err); err != nil {
+			return err
+		}
+	}
+	// Fiber's strings point into reused buffers; labels outlive the request
+	method := utils.CopyString(c.Method())
+	status := strconv.Itoa(c.Response().StatusCode())
+
+	httpRequests.WithLabelValues(method, route, status).Inc()
+	httpDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	return nil
+}
@@ -1,60 +1,243 @@
 package middleware
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/Koifish2004/ProfessorWeb/cache"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/logging"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/tracing"
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/Koifish2004/ProfessorWeb/middleware")
+
+// AuthServiceURL is where grademyprofAuth runs, overridden by AUTH_SERVICE_URL.
+var AuthServiceURL = "http://localhost:8080"
+
+// Roles grademyprofAuth puts in tokens (MODERATOR_EMAILS and ADMIN_EMAILS
+// there). Admins can do everything moderators can.
+const (
+	RoleStudent   = "student"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// AuthMiddleware lets through requests with a valid token only, and puts
+// the user's email, role and account creation time in Locals.
 func AuthMiddleware(c *fiber.Ctx) error {
+	if err := verifyOnce(c); err != nil {
+		return err
+	}
+	return c.Next()
+}
+
+// IdentifyUser verifies the request's token, if it has one, so the global
+// rate limiter mounted after it can key by user. Requests without a valid
+// token carry on anonymously; AuthMiddleware turns them away where a user
+// is required, without asking the auth service a second time.
+func IdentifyUser(c *fiber.Ctx) error {
+	if c.Get("Authorization") != "" {
+		verifyOnce(c)
+	}
+	return c.Next()
+}
+
+// verified is the outcome of verifying a request's token.
+type verified struct{ err error }
+
+// verifyOnce calls verifyToken the first time it's asked about a request
+// and repeats its answer after that.
+func verifyOnce(c *fiber.Ctx) error {
+	if v, ok := c.Locals("auth_verified").(verified); ok {
+		return v.err
+	}
+	err := verifyToken(c)
+	c.Locals("auth_verified", verified{err})
+	return err
+}
+
+func verifyToken(c *fiber.Ctx) error {
     authHeader := c.Get("Authorization")
     if authHeader == "" {
-        return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-            "error": "Missing authorization token",
-        })
+        return problem.Unauthorized(problem.CodeMissingToken, "Missing authorization token")
     }
 
-    req, err := http.NewRequest("GET", "http://localhost:8080/verify-token", nil)
+    ctx := c.UserContext()
+    resp, err := fetchVerification(ctx, authHeader)
     if err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "error": "Failed to verify token",
-        })
+        slog.WarnContext(ctx, "auth service unreachable", "err", err)
+        return problem.New(fiber.StatusServiceUnavailable, problem.CodeAuthUnavailable, "Auth service unavailable")
     }
 
-    req.Header.Set("Authorization", authHeader)
-
-    client := &http.Client{}
-    resp, err := client.Do(req)
-    if err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "error": "Auth service unavailable",
-        })
-    }
-    defer resp.Body.Close()
-
     if resp.StatusCode == http.StatusUnauthorized {
-        body, _ := io.ReadAll(resp.Body)
-        var authError map[string]interface{}
-        json.Unmarshal(body, &authError)
-        
-        return c.Status(fiber.StatusUnauthorized).JSON(authError)
+        // Keep the auth service's code (missing_token, invalid_token, ...)
+        // but answer with our own envelope and request ID
+        var authProblem problem.Problem
+        if err := json.Unmarshal(resp.Body, &authProblem); err != nil || authProblem.Code == "" {
+            return problem.Unauthorized(problem.CodeInvalidToken, "Invalid or expired token")
+        }
+
+        return problem.Unauthorized(authProblem.Code, authProblem.Message)
     }
 
     if resp.StatusCode == http.StatusOK {
-        body, _ := io.ReadAll(resp.Body)
         var authResponse map[string]interface{}
-        json.Unmarshal(body, &authResponse)
+        json.Unmarshal(resp.Body, &authResponse)
         
         if email, ok := authResponse["email"].(string); ok {
             c.Locals("user_email", email)
         }
-        
-        return c.Next()
+        role, _ := authResponse["role"].(string)
+        if role == "" {
+            role = RoleStudent
+        }
+        c.Locals("user_role", role)
+        // Unix seconds, missing for tokens issued before it was recorded
+        if created, ok := authResponse["account_created"].(float64); ok {
+            c.Locals("account_created", time.Unix(int64(created), 0))
+        }
+
+        return nil
     }
 
-    return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-        "error": "Failed to verify token",
-    })
-}
\ No newline at end of file
+    slog.WarnContext(ctx, "auth service returned an unexpected status", "status", resp.StatusCode)
+    return problem.New(fiber.StatusBadGateway, problem.CodeAuthUnavailable, "Failed to verify token")
+}
+
+// authClient calls grademyprofAuth. A hung auth service fails requests
+// after the timeout instead of holding them open.
+var authClient = &http.Client{Timeout: 5 * time.Second}
+
+// VerificationCacheTTL is how long a token's verification is reused
+// (AUTH_CACHE_TTL), so browsing doesn't ask grademyprofAuth on every
+// request. A token revoked elsewhere keeps working here for up to that
+// long; 0 turns the cache off.
+var VerificationCacheTTL = 10 * time.Second
+
+// verifications caches /verify-token answers by a hash of the
+// Authorization header, tagged with the user's email so RevokeTokens can
+// drop all of theirs.
+var verifications = cache.NewLRU(10000)
+
+// verification is a /verify-token answer.
+type verification struct {
+	StatusCode int    `json:"status_code"`
+	Body       []byte `json:"body"`
+}
+
+// fetchVerification asks grademyprofAuth about authHeader, or reuses its
+// answer from the last VerificationCacheTTL. Only valid and invalid
+// verdicts are cached, never errors.
+func fetchVerification(ctx context.Context, authHeader string) (verification, error) {
+	key := verificationKey(authHeader)
+	if v, ok := cachedVerification(key); ok {
+		return v, nil
+	}
+
+	ctx, span := tracer.Start(ctx, "auth.verify_token", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", AuthServiceURL+"/verify-token", nil)
+	if err != nil {
+		return verification{}, err
+	}
+	req.Header.Set("Authorization", authHeader)
+	req.Header.Set(logging.RequestIDHeader, logging.RequestID(ctx))
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := authClient.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "auth service unreachable")
+		return verification{}, err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "auth service unreachable")
+		return verification{}, err
+	}
+	v := verification{StatusCode: resp.StatusCode, Body: body}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusUnauthorized:
+		if encoded, err := json.Marshal(v); err == nil && VerificationCacheTTL > 0 {
+			var tags []string
+			if tag := v.userTag(); tag != "" {
+				tags = append(tags, tag)
+			}
+			verifications.Set(key, encoded, VerificationCacheTTL, tags...)
+		}
+	default:
+		span.SetStatus(codes.Error, "unexpected status")
+	}
+	return v, nil
+}
+
+func cachedVerification(key string) (verification, bool) {
+	cached, ok := verifications.Get(key)
+	if !ok {
+		return verification{}, false
+	}
+	var v verification
+	return v, json.Unmarshal(cached, &v) == nil
+}
+
+// userTag is the cache tag of the user v verified, if any.
+func (v verification) userTag() string {
+	var authResponse struct {
+		Email string `json:"email"`
+	}
+	if v.StatusCode != http.StatusOK || json.Unmarshal(v.Body, &authResponse) != nil || authResponse.Email == "" {
+		return ""
+	}
+	return "user:" + verificationKey(authResponse.Email)
+}
+
+// forgetVerifications drops the cached verifications of the user behind
+// authHeader, every token of theirs, once they've been revoked.
+func forgetVerifications(authHeader string) {
+	key := verificationKey(authHeader)
+	if v, ok := cachedVerification(key); ok {
+		if tag := v.userTag(); tag != "" {
+			verifications.Invalidate(tag)
+		}
+	}
+}
+
+// verificationKey keeps tokens themselves out of the cache.
+func verificationKey(authHeader string) string {
+	sum := sha256.Sum256([]byte(authHeader))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequireModerator lets through moderators and admins only. Mount it after
+// AuthMiddleware.
+func RequireModerator(c *fiber.Ctx) error {
+	if role := c.Locals("user_role"); role != RoleModerator && role != RoleAdmin {
+		return problem.Forbidden("Moderator access required")
+	}
+	return c.Next()
+}
+
+// RequireAdmin lets through admins only. Mount it after AuthMiddleware.
+func RequireAdmin(c *fiber.Ctx) error {
+	if c.Locals("user_role") != RoleAdmin {
+		return problem.Forbidden("Admin access required")
+	}
+	return c.Next()
+}
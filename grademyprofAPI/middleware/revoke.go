@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/logging"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/tracing"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RevokeTokens asks grademyprofAuth to revoke every token issued to the
+// user behind authHeader, the request's Authorization header. That one
+// stops working too, and so do their verifications cached here.
+func RevokeTokens(ctx context.Context, authHeader string) error {
+	ctx, span := tracer.Start(ctx, "auth.revoke_tokens", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", AuthServiceURL+"/revoke", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authHeader)
+	req.Header.Set(logging.RequestIDHeader, logging.RequestID(ctx))
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := authClient.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "auth service unreachable")
+		return err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		span.SetStatus(codes.Error, "unexpected status")
+		return fmt.Errorf("auth service returned status %d", resp.StatusCode)
+	}
+	forgetVerifications(authHeader)
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/Koifish2004/ProfessorWeb/fiberkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Values of reviews.moderation_status (migrations/007_review_moderation.sql).
+// Only approved reviews are listed publicly or count towards professor
+// stats; the abuse detector (abuse.go) holds suspicious ones.
+const (
+	statusApproved = "approved"
+	statusHeld     = "held"
+	statusRejected = "rejected"
+)
+
+// publicOnly filters a reviews query down to publicly visible reviews:
+// approved and not deleted (deletion.go).
+const publicOnly = "moderation_status=eq." + statusApproved + "&" + notDeleted
+
+// ModeratedReview is a review as moderators see it, with the reasons it
+// was flagged.
+type ModeratedReview struct {
+	Review
+	ModerationFlags []string `json:"moderation_flags"`
+}
+
+// getModerationQueue lists reviews in one moderation state, held by
+// default, oldest first so the longest-waiting get cleared first.
+func getModerationQueue(c *fiber.Ctx) error {
+	status := c.Query("status", statusHeld)
+	if !slices.Contains([]string{statusApproved, statusHeld, statusRejected}, status) {
+		return problem.BadRequest(problem.CodeInvalidParameter, "status must be approved, held or rejected")
+	}
+
+	reviews := []ModeratedReview{}
+	path := fmt.Sprintf("reviews?moderation_status=eq.%s&%s&order=created_at.asc", status, notDeleted)
+	if err := supabase.get(c.UserContext(), "list_moderation_queue", path, &reviews); err != nil {
+		return problem.Upstream("Failed to fetch moderation queue")
+	}
+	return c.JSON(reviews)
+}
+
+func approveReview(c *fiber.Ctx) error {
+	return moderateReview(c, statusApproved)
+}
+
+func rejectReview(c *fiber.Ctx) error {
+	return moderateReview(c, statusRejected)
+}
+
+// moderateReview moves a review to status. Approving a held review puts it
+// in listings and the professor's stats; rejecting takes it out of both.
+func moderateReview(c *fiber.Ctx, status string) error {
+	reviewID, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return problem.BadRequest(problem.CodeInvalidParameter, "Review ID must be a number")
+	}
+
+	// A deleted review is out of the queue, and out of reach, until it's
+	// restored
+	var current []ModeratedReview
+	path := fmt.Sprintf("reviews?id=eq.%d&%s", reviewID, notDeleted)
+	if err := supabase.get(c.UserContext(), "find_review", path+"&select=id,moderation_status,moderation_flags,deleted_at", &current); err != nil {
+		return problem.Upstream("Failed to update review")
+	}
+	if len(current) == 0 {
+		return problem.NotFound("Review not found")
+	}
+
+	var updated []ModeratedReview
+	if err := supabase.patch(c.UserContext(), "moderate_review", path, fiber.Map{"moderation_status": status}, &updated); err != nil {
+		return problem.Upstream("Failed to update review")
+	}
+	if len(updated) == 0 {
+		return problem.NotFound("Review not found")
+	}
+
+	review := updated[0]
+	slog.InfoContext(c.UserContext(), "review moderated", "review_id", reviewID, "status", status, "moderator", c.Locals("user_email"))
+
+	action := audit.ActionReviewApprove
+	if status == statusRejected {
+		action = audit.ActionReviewReject
+	}
+	entry := fiberkit.AuditEntry(c, action, "review", strconv.Itoa(reviewID))
+	entry.Before = audit.Snapshot(fiber.Map{"moderation_status": current[0].ModerationStatus, "moderation_flags": current[0].ModerationFlags})
+	entry.After = audit.Snapshot(review)
+	recordAudit(c.UserContext(), entry)
+	if current[0].ModerationStatus != status {
+		emitReviewChange(c.UserContext(), &current[0].Review, &review.Review)
+	}
+
+	professorID := strconv.Itoa(review.ProfessorID)
+	invalidateCache("reviews:" + professorID)
+	scheduleStatsUpdate(c.UserContext(), professorID)
+
+	return c.JSON(review)
+}
@@ -0,0 +1,743 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/cache"
+	"github.com/Koifish2004/ProfessorWeb/config"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/middleware"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Tokens the fake auth service accepts, see fakeAuth.
+const (
+	studentToken   = "Bearer student"
+	moderatorToken = "Bearer moderator"
+	adminToken     = "Bearer admin"
+
+	// METRICS_TOKEN, which only /metrics takes
+	metricsToken = "Bearer metrics"
+)
+
+// contractCase is one request the contract test sends, in order: later
+// cases rely on what earlier ones wrote. The response body must contain
+// none of absent.
+type contractCase struct {
+	method string
+	path   string
+	token  string
+	body   string
+	status int
+	absent []string
+}
+
+// deletedAccountData is what the student's deleted account leaves nowhere:
+// their review's comment and name, their handle and their reviewer ID.
+var deletedAccountData = []string{
+	"Explains proofs", `"student_name":"Student"`, "proof_fan", "00000000-0000-4000-8000-000000001001",
+}
+
+var contractCases = []contractCase{
+	{method: "GET", path: "/healthz", status: 200},
+	{method: "GET", path: "/readyz", status: 200},
+	{method: "GET", path: "/metrics", status: 401},
+	{method: "GET", path: "/metrics", token: metricsToken, status: 200},
+	{method: "GET", path: "/", status: 200},
+	{method: "GET", path: "/openapi.json", status: 200},
+	{method: "GET", path: "/docs", status: 200},
+	{method: "GET", path: "/api/versions", status: 200},
+
+	{method: "GET", path: "/api/v1/professors?campus=pilani", status: 200},
+	// The campus is one filter value, not more of the query
+	{method: "GET", path: "/api/v1/professors?campus=pilani%26id%3Deq.1", status: 200, absent: []string{"Asha Rao"}},
+	{method: "GET", path: "/api/v1/professors/compare?ids=1,2", status: 200},
+	{method: "GET", path: "/api/v1/professors/1", status: 200},
+	{method: "GET", path: "/api/v1/professors/1/reviews", status: 200},
+	{method: "GET", path: "/api/v1/professors/999", status: 404},
+	{method: "GET", path: "/api/v1/campuses", status: 200},
+	{method: "GET", path: "/api/v1/campuses/pilani/departments/CS", status: 200},
+
+	{method: "POST", path: "/api/v1/admin/webhooks", token: adminToken, status: 201,
+		body: `{"url":"https://93.184.215.14/hooks","events":["review.created","review.updated","review.deleted"],"description":"Campus newsletter"}`},
+
+	{method: "POST", path: "/api/v1/professors/3/reviews", body: `{"rating":4}`, status: 401},
+	{method: "POST", path: "/api/v1/professors/3/reviews", token: studentToken, status: 200,
+		body: `{"student_name":"Student","rating":4,"difficulty":3,"would_take_again":true,"course":"MATH F111","comment":"Explains proofs slowly and answers every question in office hours."}`},
+	{method: "GET", path: "/api/v1/professors/3/user-review", token: studentToken, status: 200},
+	{method: "PATCH", path: "/api/v1/professors/3/reviews/4", token: studentToken, status: 200,
+		body: `{"student_name":"Student","rating":5,"difficulty":3,"would_take_again":true,"course":"MATH F111","comment":"Explains proofs slowly, answers every question and posts worked solutions."}`},
+	{method: "DELETE", path: "/api/v1/professors/3/reviews/4", token: studentToken, status: 200},
+	{method: "POST", path: "/api/v1/reviews/4/restore", token: studentToken, status: 200},
+	{method: "GET", path: "/api/v1/reviews/4/history", token: moderatorToken, status: 200},
+
+	{method: "GET", path: "/api/v1/moderation/reviews", token: studentToken, status: 403},
+	{method: "GET", path: "/api/v1/moderation/reviews", token: moderatorToken, status: 200},
+	{method: "POST", path: "/api/v1/moderation/reviews/2/approve", token: moderatorToken, status: 200},
+	{method: "POST", path: "/api/v1/moderation/reviews/3/reject", token: moderatorToken, status: 200},
+
+	{method: "GET", path: "/api/v1/me", token: studentToken, status: 200},
+	{method: "PATCH", path: "/api/v1/me", token: studentToken, body: `{"handle":"proof_fan"}`, status: 200},
+	{method: "GET", path: "/api/v1/me/reviews", token: studentToken, status: 200},
+	{method: "GET", path: "/api/v1/me/export", token: studentToken, status: 200},
+
+	{method: "GET", path: "/api/v1/admin/webhooks", token: adminToken, status: 200},
+	{method: "PATCH", path: "/api/v1/admin/webhooks/1", token: adminToken, body: `{"events":["review.created","review.deleted"]}`, status: 200},
+	{method: "GET", path: "/api/v1/admin/webhooks/1/deliveries", token: adminToken, status: 200},
+	{method: "POST", path: "/api/v1/admin/webhooks/1/deliveries/1/replay", token: adminToken, status: 202},
+	{method: "GET", path: "/api/v1/admin/audit", token: adminToken, status: 200},
+
+	// Delivery 2 is review 4 being created, with the student's name and
+	// comment, until they delete their account
+	{method: "DELETE", path: "/api/v1/me", token: studentToken, status: 200},
+	{method: "POST", path: "/api/v1/admin/webhooks/1/deliveries/2/replay", token: adminToken, status: 202, absent: deletedAccountData},
+	{method: "GET", path: "/api/v1/admin/webhooks/1/deliveries?limit=100", token: adminToken, status: 200, absent: deletedAccountData},
+	{method: "GET", path: "/api/v1/admin/audit?limit=100", token: adminToken, status: 200, absent: deletedAccountData},
+	{method: "DELETE", path: "/api/v1/admin/webhooks/1", token: adminToken, status: 200},
+}
+
+// TestOpenAPIContract sends contractCases through the app and checks every
+// response against openapi.json, then that every documented operation was
+// called and every route is documented.
+func TestOpenAPIContract(t *testing.T) {
+	app := newTestApp(t)
+	doc := loadOpenAPI(t)
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := map[string]bool{}
+	for _, tc := range contractCases {
+		name := tc.method + " " + tc.path
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if tc.token != "" {
+			req.Header.Set("Authorization", tc.token)
+		}
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: got status %d, want %d: %s", name, resp.StatusCode, tc.status, body)
+			continue
+		}
+		for _, text := range tc.absent {
+			if strings.Contains(string(body), text) {
+				t.Errorf("%s: response contains %s: %s", name, text, body)
+			}
+		}
+
+		route, pathParams, err := router.FindRoute(req)
+		if err != nil {
+			t.Errorf("%s: not in openapi.json: %v", name, err)
+			continue
+		}
+		called[route.Method+" "+route.Path] = true
+
+		if err := validateResponse(req, route, pathParams, resp, body); err != nil {
+			t.Errorf("%s: response doesn't match openapi.json: %v", name, err)
+		}
+	}
+
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			if !called[method+" "+path] {
+				t.Errorf("%s %s is documented but no contract case calls it", method, path)
+			}
+		}
+	}
+
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead || route.Method == fiber.MethodOptions {
+			continue
+		}
+		item := doc.Paths.Find(fiberParam.ReplaceAllString(route.Path, "{$1}"))
+		if item == nil || item.GetOperation(route.Method) == nil {
+			t.Errorf("%s %s is not documented in openapi.json", route.Method, route.Path)
+		}
+	}
+}
+
+var fiberParam = regexp.MustCompile(`:(\w+)`)
+
+func loadOpenAPI(t *testing.T) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	// Match on paths only, wherever the API happens to be served
+	doc.Servers = nil
+	return doc
+}
+
+func validateResponse(req *http.Request, route *routers.Route, pathParams map[string]string, resp *http.Response, body []byte) error {
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+		},
+	}
+	input.SetBodyBytes(body)
+	return openapi3filter.ValidateResponse(context.Background(), input)
+}
+
+func init() {
+	// The docs page is the only HTML response
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.FileBodyDecoder)
+}
+
+// newTestApp builds the app against a fake Supabase and auth service.
+func newTestApp(t *testing.T) *fiber.App {
+	t.Helper()
+	supabaseServer := httptest.NewServer(newFakeSupabase(t))
+	t.Cleanup(supabaseServer.Close)
+	authServer := httptest.NewServer(http.HandlerFunc(fakeAuth))
+	t.Cleanup(authServer.Close)
+
+	t.Setenv("SUPABASE_URL", supabaseServer.URL)
+	t.Setenv("SUPABASE_ANON_KEY", "anon")
+	t.Setenv("SUPABASE_SERVICE_ROLE_KEY", "service")
+	t.Setenv("AUDIT_KEY", "test")
+	t.Setenv("METRICS_TOKEN", strings.TrimPrefix(metricsToken, "Bearer "))
+	t.Setenv("AUTH_SERVICE_URL", authServer.URL)
+	loaded, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = loaded
+
+	supabase = &SupabaseClient{URL: cfg.SupabaseURL, APIKey: cfg.SupabaseAnonKey}
+	supabaseService = &SupabaseClient{URL: cfg.SupabaseURL, APIKey: cfg.SupabaseServiceRoleKey}
+	middleware.AuthServiceURL = cfg.AuthServiceURL
+	audit.Service = "grademyprofAPI"
+	audit.PseudonymKey = []byte(cfg.AuditKey)
+	responseCache = cache.NewLRU(cfg.CacheSize)
+
+	app, err := newApp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+// fakeAuth answers grademyprofAuth's /verify-token, /revoke and /healthz
+// for the tokens above. Every account is a year old.
+func fakeAuth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/healthz" {
+		fmt.Fprint(w, `{"status":"ok"}`)
+		return
+	}
+
+	users := map[string][2]string{
+		studentToken:   {"student@pilani.bits-pilani.ac.in", middleware.RoleStudent},
+		moderatorToken: {"moderator@pilani.bits-pilani.ac.in", middleware.RoleModerator},
+		adminToken:     {"admin@pilani.bits-pilani.ac.in", middleware.RoleAdmin},
+	}
+	user, ok := users[r.Header.Get("Authorization")]
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"Invalid or expired token"}`)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/verify-token":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"valid":           true,
+			"email":           user[0],
+			"role":            user[1],
+			"account_created": time.Now().AddDate(-1, 0, 0).Unix(),
+		})
+	case "/revoke":
+		json.NewEncoder(w).Encode(map[string]interface{}{"revoked_at": time.Now().Unix()})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// fakeSupabaseSeed is the database the contract cases start from.
+const fakeSupabaseSeed = `{
+	"professor": [
+		{"id": 1, "name": "Asha Rao", "department": "CS", "campus": "pilani", "university": "BITS Pilani",
+		 "average_rating": 5, "review_count": 1, "average_difficulty": 3, "would_take_again_percent": 100,
+		 "updated_at": "2026-01-02T00:00:00Z"},
+		{"id": 2, "name": "Vikram Sen", "department": "CS", "campus": "pilani", "university": "BITS Pilani",
+		 "average_rating": 0, "review_count": 0, "average_difficulty": 0, "would_take_again_percent": 0,
+		 "updated_at": "2026-01-02T00:00:00Z"},
+		{"id": 3, "name": "Meera Iyer", "department": "Math", "campus": "pilani", "university": "BITS Pilani",
+		 "average_rating": 0, "review_count": 0, "average_difficulty": 0, "would_take_again_percent": 0,
+		 "updated_at": "2026-01-02T00:00:00Z"}
+	],
+	"reviews": [
+		{"id": 1, "professor_id": 1, "user_email": "first@pilani.bits-pilani.ac.in", "student_name": "First",
+		 "rating": 5, "difficulty": 3, "would_take_again": true, "course": "CS F111", "comment": "Clear lectures and fair exams.",
+		 "created_at": "2026-01-01T00:00:00Z", "updated_at": "2026-01-01T00:00:00Z", "moderation_status": "approved",
+		 "moderation_flags": [], "edit_count": 0, "deleted_at": null, "reviewer_id": null, "reviewer_name": "Calm Otter 0001"},
+		{"id": 2, "professor_id": 2, "user_email": "second@pilani.bits-pilani.ac.in", "student_name": "Second",
+		 "rating": 4, "difficulty": 2, "would_take_again": true, "course": "CS F211", "comment": "Good examples in every class.",
+		 "created_at": "2026-01-01T00:00:00Z", "updated_at": "2026-01-01T00:00:00Z", "moderation_status": "held",
+		 "moderation_flags": ["new_account"], "edit_count": 0, "deleted_at": null, "reviewer_id": null, "reviewer_name": "Keen Fox 0002"},
+		{"id": 3, "professor_id": 2, "user_email": "third@pilani.bits-pilani.ac.in", "student_name": "Third",
+		 "rating": 1, "difficulty": 5, "would_take_again": false, "course": "CS F211", "comment": "Worst class ever, avoid.",
+		 "created_at": "2026-01-01T00:00:00Z", "updated_at": "2026-01-01T00:00:00Z", "moderation_status": "held",
+		 "moderation_flags": ["review_burst"], "edit_count": 0, "deleted_at": null, "reviewer_id": null, "reviewer_name": "Tidy Yak 0003"}
+	],
+	"webhook_deliveries": [
+		{"id": 1, "subscription_id": 1, "event_id": "8f0e3a52-6d8a-4c39-9a55-0c8b0d7e3c11", "event": "review.created",
+		 "payload": {"id": "8f0e3a52-6d8a-4c39-9a55-0c8b0d7e3c11", "type": "review.created", "created_at": "2026-01-01T00:00:00Z",
+		  "data": {"id": 1, "professor_id": 1, "student_name": "First", "rating": 5, "difficulty": 3, "would_take_again": true,
+		   "course": "CS F111", "comment": "Clear lectures and fair exams.", "created_at": "2026-01-01T00:00:00Z",
+		   "updated_at": "2026-01-01T00:00:00Z", "edit_count": 0, "reviewer_id": null, "reviewer_name": "Calm Otter 0001"}},
+		 "status": "failed", "attempts": 8, "next_attempt_at": null, "last_attempt_at": "2026-01-01T00:00:00Z",
+		 "last_status_code": 500, "last_error": "status 500", "created_at": "2026-01-01T00:00:00Z",
+		 "delivered_at": null, "replay_of": null}
+	]
+}`
+
+// fakeSupabaseDefaults are the column defaults of the tables the API
+// inserts into.
+var fakeSupabaseDefaults = map[string]map[string]interface{}{
+	"reviews": {
+		"moderation_status": "approved", "moderation_flags": []interface{}{}, "edit_count": 0.0,
+		"deleted_at": nil, "reviewer_id": nil, "reviewer_name": "Anonymous",
+	},
+	"reviewers":             {"handle": nil},
+	"webhook_subscriptions": {"description": "", "active": true},
+	"webhook_deliveries": {
+		"status": "pending", "attempts": 0.0, "last_attempt_at": nil, "last_status_code": nil,
+		"last_error": nil, "delivered_at": nil, "replay_of": nil,
+	},
+}
+
+// serviceOnly are the tables and functions the anon key has no grants on.
+var serviceOnly = map[string]bool{
+	"audit_log":                      true,
+	"reviewers":                      true,
+	"review_revisions":               true,
+	"webhook_subscriptions":          true,
+	"webhook_deliveries":             true,
+	"rpc/anonymize_review_revisions": true,
+	"rpc/redact_account_data":        true,
+}
+
+// fakeSupabase is an in-memory PostgREST covering the filters, ordering
+// and writes the API uses.
+type fakeSupabase struct {
+	t      *testing.T
+	mu     sync.Mutex
+	tables map[string][]map[string]interface{}
+	nextID int
+}
+
+func newFakeSupabase(t *testing.T) *fakeSupabase {
+	f := &fakeSupabase{t: t, nextID: 1000}
+	if err := json.Unmarshal([]byte(fakeSupabaseSeed), &f.tables); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fakeSupabase) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := strings.TrimPrefix(r.URL.Path, "/rest/v1/")
+	if serviceOnly[name] && r.Header.Get("apikey") != "service" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "permission denied for " + name})
+		return
+	}
+	if name == "rpc/redact_account_data" {
+		f.redactAccountData(w, r)
+		return
+	}
+	if strings.HasPrefix(name, "rpc/") {
+		// Revisions are never read back as they'd be anonymized
+		writeJSON(w, http.StatusOK, 0)
+		return
+	}
+	query := r.URL.Query()
+	rows := f.tables[name]
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, selectRows(filterRows(rows, query), query))
+
+	case http.MethodPost:
+		var input interface{}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		inserts, ok := input.([]interface{})
+		if !ok {
+			inserts = []interface{}{input}
+		}
+		var created []map[string]interface{}
+		for _, insert := range inserts {
+			row := map[string]interface{}{}
+			for column, value := range fakeSupabaseDefaults[name] {
+				row[column] = value
+			}
+			for column, value := range insert.(map[string]interface{}) {
+				row[column] = columnValue(column, value)
+			}
+			f.fillKeys(name, row)
+			if name == "pending_stats" {
+				rows = slicesDelete(rows, func(existing map[string]interface{}) bool {
+					return fmt.Sprint(existing["professor_id"]) == fmt.Sprint(row["professor_id"])
+				})
+			}
+			rows = append(rows, row)
+			created = append(created, row)
+		}
+		f.tables[name] = rows
+		writeJSON(w, http.StatusCreated, selectRows(created, query))
+
+	case http.MethodPatch:
+		var changes map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		updated := filterRows(rows, query)
+		for _, row := range updated {
+			for column, value := range changes {
+				row[column] = columnValue(column, value)
+			}
+		}
+		writeJSON(w, http.StatusOK, selectRows(updated, query))
+
+	case http.MethodDelete:
+		removed := filterRows(rows, query)
+		f.tables[name] = slicesDelete(rows, func(row map[string]interface{}) bool {
+			return matchRow(row, query)
+		})
+		writeJSON(w, http.StatusOK, selectRows(removed, query))
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// redactAccountData is migrations/022_account_redaction.sql's
+// redact_account_data.
+func (f *fakeSupabase) redactAccountData(w http.ResponseWriter, r *http.Request) {
+	var args struct {
+		ReviewIDs    []int   `json:"review_ids"`
+		ReviewerID   *string `json:"reviewer_id"`
+		KeepComments bool    `json:"keep_comments"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+	ofAccount := func(review interface{}) bool {
+		data, ok := review.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if args.ReviewerID != nil && data["reviewer_id"] == *args.ReviewerID {
+			return true
+		}
+		id, _ := data["id"].(float64)
+		return slices.Contains(args.ReviewIDs, int(id))
+	}
+
+	redacted := 0
+	for _, delivery := range f.tables["webhook_deliveries"] {
+		payload, _ := delivery["payload"].(map[string]interface{})
+		if strings.HasPrefix(fmt.Sprint(delivery["event"]), "review.") && ofAccount(payload["data"]) {
+			redactReviewJSON(payload["data"], args.KeepComments)
+			redacted++
+		}
+	}
+	for _, entry := range f.tables["audit_log"] {
+		id, _ := strconv.Atoi(fmt.Sprint(entry["target_id"]))
+		if entry["target_type"] == "review" && slices.Contains(args.ReviewIDs, id) || ofAccount(entry["before"]) || ofAccount(entry["after"]) {
+			redactReviewJSON(entry["before"], args.KeepComments)
+			redactReviewJSON(entry["after"], args.KeepComments)
+			redacted++
+		}
+	}
+	writeJSON(w, http.StatusOK, redacted)
+}
+
+// redactReviewJSON is redact_review_json, in place.
+func redactReviewJSON(review interface{}, keepComment bool) {
+	data, ok := review.(map[string]interface{})
+	if !ok {
+		return
+	}
+	delete(data, "user_email")
+	replacements := map[string]interface{}{"student_name": "Anonymous", "reviewer_name": "Anonymous", "reviewer_id": nil}
+	if !keepComment {
+		replacements["comment"] = ""
+	}
+	for key, value := range replacements {
+		if _, ok := data[key]; ok {
+			data[key] = value
+		}
+	}
+}
+
+// fillKeys sets the columns the database would: ids and timestamps.
+func (f *fakeSupabase) fillKeys(table string, row map[string]interface{}) {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	switch table {
+	case "pending_stats", "review_revisions":
+	case "reviewers":
+		f.nextID++
+		row["id"] = fmt.Sprintf("00000000-0000-4000-8000-%012d", f.nextID)
+	default:
+		if _, ok := row["id"]; !ok {
+			highest := 0.0
+			for _, existing := range f.tables[table] {
+				if id, ok := existing["id"].(float64); ok && id > highest {
+					highest = id
+				}
+			}
+			row["id"] = highest + 1
+		}
+	}
+	if _, ok := row["created_at"]; !ok {
+		row["created_at"] = now
+	}
+	if table == "reviews" || table == "webhook_subscriptions" {
+		row["updated_at"] = now
+	}
+}
+
+// integerColumns are the integer columns the API may send as strings.
+var integerColumns = map[string]bool{"professor_id": true, "review_id": true, "subscription_id": true}
+
+// columnValue is value as the database stores it in column.
+func columnValue(column string, value interface{}) interface{} {
+	if text, ok := value.(string); ok && integerColumns[column] {
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			return number
+		}
+	}
+	return value
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func slicesDelete(rows []map[string]interface{}, del func(map[string]interface{}) bool) []map[string]interface{} {
+	var kept []map[string]interface{}
+	for _, row := range rows {
+		if !del(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// reserved are query parameters that aren't column filters.
+var reserved = map[string]bool{"select": true, "order": true, "limit": true, "offset": true, "on_conflict": true}
+
+func filterRows(rows []map[string]interface{}, query url.Values) []map[string]interface{} {
+	var matched []map[string]interface{}
+	for _, row := range rows {
+		if matchRow(row, query) {
+			matched = append(matched, row)
+		}
+	}
+	return matched
+}
+
+func matchRow(row map[string]interface{}, query url.Values) bool {
+	for column, filters := range query {
+		if reserved[column] {
+			continue
+		}
+		for _, filter := range filters {
+			negate := strings.HasPrefix(filter, "not.")
+			filter = strings.TrimPrefix(filter, "not.")
+			op, operand, _ := strings.Cut(filter, ".")
+			if matchFilter(row[column], op, operand) == negate {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchFilter(value interface{}, op, operand string) bool {
+	switch op {
+	case "eq":
+		return value != nil && formatValue(value) == operand
+	case "neq":
+		return value == nil || formatValue(value) != operand
+	case "is":
+		if operand == "null" {
+			return value == nil
+		}
+		return value != nil && formatValue(value) == operand
+	case "in":
+		for _, item := range strings.Split(strings.Trim(operand, "()"), ",") {
+			if value != nil && formatValue(value) == strings.Trim(item, `"`) {
+				return true
+			}
+		}
+		return false
+	case "gt", "gte", "lt", "lte":
+		if value == nil {
+			return false
+		}
+		c := compareValues(value, operand)
+		return map[string]bool{"gt": c > 0, "gte": c >= 0, "lt": c < 0, "lte": c <= 0}[op]
+	case "like":
+		pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(operand), `\*`, ".*") + "$"
+		return value != nil && regexp.MustCompile(pattern).MatchString(formatValue(value))
+	case "cs", "ov":
+		items, _ := value.([]interface{})
+		have := map[string]bool{}
+		for _, item := range items {
+			have[formatValue(item)] = true
+		}
+		wanted := strings.Split(strings.Trim(operand, "{}"), ",")
+		found := 0
+		for _, item := range wanted {
+			if have[item] {
+				found++
+			}
+		}
+		if op == "cs" {
+			return found == len(wanted)
+		}
+		return found > 0
+	}
+	panic("fake supabase: unsupported filter " + op)
+}
+
+func formatValue(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// compareValues compares a column value with a filter operand or another
+// value, as numbers or timestamps when both parse as one.
+func compareValues(a interface{}, b interface{}) int {
+	x, y := formatValue(a), formatValue(b)
+	if m, err := strconv.ParseFloat(x, 64); err == nil {
+		if n, err := strconv.ParseFloat(y, 64); err == nil {
+			return compareOrdered(m, n)
+		}
+	}
+	if m, err := time.Parse(time.RFC3339Nano, x); err == nil {
+		if n, err := time.Parse(time.RFC3339Nano, y); err == nil {
+			return m.Compare(n)
+		}
+	}
+	return strings.Compare(x, y)
+}
+
+func compareOrdered(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// selectRows applies order, offset, limit and select to rows, copying
+// them so callers can't change the table.
+func selectRows(rows []map[string]interface{}, query url.Values) []map[string]interface{} {
+	sorted := append([]map[string]interface{}(nil), rows...)
+	if order := query.Get("order"); order != "" {
+		terms := strings.Split(order, ",")
+		sort.SliceStable(sorted, func(i, j int) bool {
+			for _, term := range terms {
+				column, direction, _ := strings.Cut(term, ".")
+				a, b := sorted[i][column], sorted[j][column]
+				var c int
+				switch {
+				case a == nil && b == nil:
+				case a == nil:
+					c = 1
+				case b == nil:
+					c = -1
+				default:
+					c = compareValues(a, b)
+					if strings.HasPrefix(direction, "desc") {
+						c = -c
+					}
+				}
+				if c != 0 {
+					return c < 0
+				}
+			}
+			return false
+		})
+	}
+	if offset, err := strconv.Atoi(query.Get("offset")); err == nil && offset < len(sorted) {
+		sorted = sorted[offset:]
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit < len(sorted) {
+		sorted = sorted[:limit]
+	}
+
+	columns := strings.Split(query.Get("select"), ",")
+	selected := make([]map[string]interface{}, 0, len(sorted))
+	for _, row := range sorted {
+		out := map[string]interface{}{}
+		for column, value := range row {
+			out[column] = value
+		}
+		if query.Get("select") != "" && query.Get("select") != "*" {
+			out = map[string]interface{}{}
+			for _, column := range columns {
+				out[column] = row[column]
+			}
+		}
+		selected = append(selected, out)
+	}
+	return selected
+}
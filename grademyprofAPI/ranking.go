@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ratingPriors holds the review-weighted mean rating per campus and per
+// campus+department, used as the prior for the Bayesian average.
+type ratingPriors struct {
+	campus     map[string]float64
+	department map[string]float64
+}
+
+func departmentKey(campus, department string) string {
+	return campus + "|" + strings.ToLower(department)
+}
+
+// computePriors derives campus and department means from professor rows,
+// weighting each professor's average by their review count.
+func computePriors(professors []Professor) ratingPriors {
+	campusSum := map[string]float64{}
+	campusCount := map[string]int{}
+	deptSum := map[string]float64{}
+	deptCount := map[string]int{}
+
+	for _, p := range professors {
+		key := departmentKey(p.Campus, p.Department)
+		campusSum[p.Campus] += p.AverageRating * float64(p.ReviewCount)
+		campusCount[p.Campus] += p.ReviewCount
+		deptSum[key] += p.AverageRating * float64(p.ReviewCount)
+		deptCount[key] += p.ReviewCount
+	}
+
+	priors := ratingPriors{
+		campus:     map[string]float64{},
+		department: map[string]float64{},
+	}
+	for campus, count := range campusCount {
+		if count > 0 {
+			priors.campus[campus] = campusSum[campus] / float64(count)
+		}
+	}
+	for key, count := range deptCount {
+		if count > 0 {
+			priors.department[key] = deptSum[key] / float64(count)
+		}
+	}
+	return priors
+}
+
+// mean returns the prior for a professor: their department mean if the
+// department has any reviews, otherwise the campus mean.
+func (p ratingPriors) mean(prof Professor) float64 {
+	if m, ok := p.department[departmentKey(prof.Campus, prof.Department)]; ok {
+		return m
+	}
+	return p.campus[prof.Campus]
+}
+
+// bayesianRating shrinks a professor's average towards the prior mean,
+// so a handful of reviews can't outrank a long, consistent record.
+func bayesianRating(average float64, count int, prior float64) float64 {
+	// RatingPriorWeight is the number of "virtual" reviews at the
+	// department mean every professor starts with
+	weight := cfg.RatingPriorWeight
+	if weight+float64(count) == 0 {
+		// No prior weight and no reviews: all there is to go on is the prior
+		return math.Round(prior*100) / 100
+	}
+	score := (weight*prior + average*float64(count)) / (weight + float64(count))
+	return math.Round(score*100) / 100
+}
+
+// applyBayesianRatings fills BayesianRating on professors using priors
+// computed from peers (usually every professor on the same campus).
+func applyBayesianRatings(professors []Professor, peers []Professor) {
+	priors := computePriors(peers)
+	for i := range professors {
+		professors[i].BayesianRating = bayesianRating(
+			professors[i].AverageRating,
+			professors[i].ReviewCount,
+			priors.mean(professors[i]),
+		)
+	}
+}
+
+// professorSorts maps the ?sort= values accepted by getProfessors to their
+// ordering. Ties fall back to review count so busier professors come first.
+var professorSorts = map[string]func(a, b Professor) bool{
+	"bayesian": func(a, b Professor) bool {
+		if a.BayesianRating != b.BayesianRating {
+			return a.BayesianRating > b.BayesianRating
+		}
+		return a.ReviewCount > b.ReviewCount
+	},
+	"rating": func(a, b Professor) bool {
+		if a.AverageRating != b.AverageRating {
+			return a.AverageRating > b.AverageRating
+		}
+		return a.ReviewCount > b.ReviewCount
+	},
+	"reviews": func(a, b Professor) bool {
+		return a.ReviewCount > b.ReviewCount
+	},
+	"name": func(a, b Professor) bool {
+		return a.Name < b.Name
+	},
+}
+
+func sortProfessors(professors []Professor, by string) error {
+	less, ok := professorSorts[by]
+	if !ok {
+		return fmt.Errorf("unknown sort %q", by)
+	}
+	sort.SliceStable(professors, func(i, j int) bool {
+		return less(professors[i], professors[j])
+	})
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Koifish2004/ProfessorWeb/config"
+)
+
+// withPriorWeight sets RATING_PRIOR_WEIGHT for one test.
+func withPriorWeight(t *testing.T, weight float64) {
+	t.Helper()
+	previous := cfg
+	cfg = &config.Config{RatingPriorWeight: weight}
+	t.Cleanup(func() { cfg = previous })
+}
+
+func TestBayesianRating(t *testing.T) {
+	tests := []struct {
+		name    string
+		weight  float64
+		average float64
+		count   int
+		prior   float64
+		want    float64
+	}{
+		{"no reviews is the prior", 10, 0, 0, 3.5, 3.5},
+		{"few reviews stay near the prior", 10, 5, 1, 3.5, 3.64},
+		{"many reviews outweigh it", 10, 4.5, 50, 3.5, 4.33},
+		{"as many reviews as the weight meet halfway", 10, 5, 10, 3, 4},
+		{"no weight is the plain average", 0, 4.2, 3, 3.5, 4.2},
+		{"no weight and no reviews is still the prior", 0, 0, 0, 3.5, 3.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withPriorWeight(t, tt.weight)
+			if got := bayesianRating(tt.average, tt.count, tt.prior); got != tt.want {
+				t.Errorf("bayesianRating(%g, %d, %g) = %g, want %g", tt.average, tt.count, tt.prior, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputePriors(t *testing.T) {
+	priors := computePriors([]Professor{
+		{Campus: "pilani", Department: "CS", AverageRating: 5, ReviewCount: 1},
+		{Campus: "pilani", Department: "cs", AverageRating: 3, ReviewCount: 3},
+		{Campus: "pilani", Department: "Math", AverageRating: 4, ReviewCount: 0},
+		{Campus: "goa", Department: "CS", AverageRating: 2, ReviewCount: 2},
+	})
+
+	tests := []struct {
+		name string
+		prof Professor
+		want float64
+	}{
+		{"department mean weighs by reviews", Professor{Campus: "pilani", Department: "CS"}, 3.5},
+		{"department without reviews uses the campus", Professor{Campus: "pilani", Department: "Math"}, 3.5},
+		{"campuses are separate", Professor{Campus: "goa", Department: "CS"}, 2},
+		{"unknown campus has no prior", Professor{Campus: "hyderabad", Department: "CS"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := priors.mean(tt.prof); got != tt.want {
+				t.Errorf("mean = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortProfessorsBayesian(t *testing.T) {
+	withPriorWeight(t, 10)
+	professors := []Professor{
+		{Name: "One Review", Campus: "pilani", Department: "CS", AverageRating: 5, ReviewCount: 1},
+		{Name: "Long Record", Campus: "pilani", Department: "CS", AverageRating: 4.5, ReviewCount: 50},
+		{Name: "Unreviewed", Campus: "pilani", Department: "CS"},
+	}
+	// The rest of the department averages 3.5
+	peers := []Professor{{Campus: "pilani", Department: "CS", AverageRating: 3.5, ReviewCount: 20}}
+	applyBayesianRatings(professors, peers)
+
+	tests := []struct {
+		by   string
+		want []string
+	}{
+		{"bayesian", []string{"Long Record", "One Review", "Unreviewed"}},
+		{"rating", []string{"One Review", "Long Record", "Unreviewed"}},
+		{"reviews", []string{"Long Record", "One Review", "Unreviewed"}},
+		{"name", []string{"Long Record", "One Review", "Unreviewed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.by, func(t *testing.T) {
+			sorted := append([]Professor(nil), professors...)
+			if err := sortProfessors(sorted, tt.by); err != nil {
+				t.Fatal(err)
+			}
+			for i, name := range tt.want {
+				if sorted[i].Name != name {
+					t.Errorf("position %d is %s, want %s", i, sorted[i].Name, name)
+				}
+			}
+		})
+	}
+
+	if err := sortProfessors(professors, "random"); err == nil {
+		t.Error("an unknown sort was accepted")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/fiberkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newRateLimitStore picks where limiter counters live (RATE_LIMIT_STORE).
+// With Redis every replica shares them; in memory each replica counts its
+// own requests.
+func newRateLimitStore(ctx context.Context) (ratelimit.Store, error) {
+	if cfg.RateLimitStore != "redis" {
+		return ratelimit.NewMemory(), nil
+	}
+
+	store, err := ratelimit.NewRedis(cfg.RedisURL)
+	if err != nil {
+		return nil, err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := store.Ping(pingCtx); err != nil {
+		// Limiters let requests through while Redis is down
+		slog.Warn("redis unreachable, rate limits are not enforced until it is back", "err", err)
+	}
+	return store, nil
+}
+
+// rateLimit builds a limiter middleware named name. Each name has its own
+// buckets, and rejections answer with message.
+func rateLimit(store ratelimit.Store, name string, limit int, window time.Duration, message string) fiber.Handler {
+	l := &ratelimit.Limiter{Name: name, Limit: limit, Window: window, Store: store}
+	return fiberkit.RateLimit(l, func(c *fiber.Ctx, res ratelimit.Result) error {
+		rateLimitRejections.WithLabelValues(name).Inc()
+		return problem.RateLimited(message).WithDetails(fiber.Map{"limiter": name})
+	})
+}
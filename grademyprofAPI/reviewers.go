@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/Koifish2004/ProfessorWeb/webhook"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Reviews never show who wrote them: each user gets a reviewers row
+// (migrations/012_reviewer_identities.sql) with an opaque ID and a random
+// pseudonym, and may pick a handle to show instead. Reviews carry the ID
+// and the name to show (reviewer_name), kept in step when the handle
+// changes. The email stays on reviews for ownership checks only and is
+// never sent to clients.
+// Only the service role may touch the reviewers table
+// (migrations/020_reviewers_service_only.sql), so the anon key can't map
+// emails to pseudonyms or rename anyone.
+
+// Reviewer is a user's public identity.
+type Reviewer struct {
+	ID        string  `json:"id"`
+	Pseudonym string  `json:"pseudonym"`
+	Handle    *string `json:"handle"`
+}
+
+// Name is what reviews show for the reviewer: their handle, or their
+// pseudonym until they pick one.
+func (r Reviewer) Name() string {
+	if r.Handle != nil {
+		return *r.Handle
+	}
+	return r.Pseudonym
+}
+
+// Handles can't contain spaces, so they never clash with a pseudonym.
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,24}$`)
+
+var (
+	pseudonymAdjectives = []string{
+		"Amber", "Brave", "Calm", "Clever", "Curious", "Eager", "Gentle", "Golden",
+		"Honest", "Jolly", "Keen", "Lively", "Lucky", "Mellow", "Nimble", "Patient",
+		"Quiet", "Rapid", "Silver", "Sunny", "Swift", "Tidy", "Witty", "Zesty",
+	}
+	pseudonymAnimals = []string{
+		"Badger", "Crane", "Dolphin", "Falcon", "Fox", "Gecko", "Heron", "Ibis",
+		"Koala", "Lynx", "Marten", "Newt", "Otter", "Owl", "Panda", "Puffin",
+		"Quokka", "Raven", "Seal", "Sparrow", "Tapir", "Tiger", "Walrus", "Yak",
+	}
+)
+
+// newPseudonym picks a random name like "Quiet Otter 4821".
+func newPseudonym() (string, error) {
+	pick := func(n int) (int, error) {
+		i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+		if err != nil {
+			return 0, err
+		}
+		return int(i.Int64()), nil
+	}
+	adjective, err := pick(len(pseudonymAdjectives))
+	if err != nil {
+		return "", err
+	}
+	animal, err := pick(len(pseudonymAnimals))
+	if err != nil {
+		return "", err
+	}
+	number, err := pick(10000)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %04d", pseudonymAdjectives[adjective], pseudonymAnimals[animal], number), nil
+}
+
+// findReviewer returns the reviewer for email, or nil if they have none yet.
+func findReviewer(ctx context.Context, email string) (*Reviewer, error) {
+	var reviewers []Reviewer
+	path := "reviewers?email=eq." + url.QueryEscape(email) + "&select=id,pseudonym,handle"
+	if err := supabaseService.get(ctx, "find_reviewer", path, &reviewers); err != nil {
+		return nil, err
+	}
+	if len(reviewers) == 0 {
+		return nil, nil
+	}
+	return &reviewers[0], nil
+}
+
+// reviewerFor returns the reviewer for email, creating one with a fresh
+// pseudonym the first time.
+func reviewerFor(ctx context.Context, email string) (Reviewer, error) {
+	// A conflict is a concurrent request creating the same reviewer, or a
+	// pseudonym that's already taken: look again, then retry with a new one
+	for attempt := 0; ; attempt++ {
+		reviewer, err := findReviewer(ctx, email)
+		if err != nil {
+			return Reviewer{}, err
+		}
+		if reviewer != nil {
+			return *reviewer, nil
+		}
+
+		pseudonym, err := newPseudonym()
+		if err != nil {
+			return Reviewer{}, err
+		}
+		var created []Reviewer
+		err = supabaseService.insert(ctx, "create_reviewer", "reviewers?select=id,pseudonym,handle",
+			fiber.Map{"email": email, "pseudonym": pseudonym}, &created)
+		if errors.Is(err, errConflict) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return Reviewer{}, err
+		}
+		if len(created) == 0 {
+			return Reviewer{}, errors.New("no reviewer returned")
+		}
+		return created[0], nil
+	}
+}
+
+// updateMe changes the signed-in user's handle; a null handle goes back to
+// the pseudonym. Their reviews show the new name straight away.
+func updateMe(c *fiber.Ctx) error {
+	var input struct {
+		Handle *string `json:"handle"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return problem.BadRequest(problem.CodeInvalidBody, "Invalid request body")
+	}
+	if input.Handle != nil && !handlePattern.MatchString(*input.Handle) {
+		return problem.BadRequest(problem.CodeInvalidParameter, "handle must be 3 to 24 letters, digits or underscores")
+	}
+
+	ctx := c.UserContext()
+	me := currentUser(c)
+	reviewer, err := reviewerFor(ctx, me.Email)
+	if err != nil {
+		slog.ErrorContext(ctx, "reviewer lookup failed", "err", err)
+		return problem.Upstream("Failed to update your profile")
+	}
+
+	var updated []Reviewer
+	path := "reviewers?id=eq." + reviewer.ID + "&select=id,pseudonym,handle"
+	if err := supabaseService.patch(ctx, "update_reviewer", path, fiber.Map{"handle": input.Handle}, &updated); err != nil {
+		if errors.Is(err, errConflict) {
+			return problem.New(fiber.StatusConflict, problem.CodeConflict, "That handle is taken")
+		}
+		return problem.Upstream("Failed to update your profile")
+	}
+	if len(updated) == 0 {
+		return problem.Upstream("Failed to update your profile")
+	}
+	reviewer = updated[0]
+
+	var renamed []Review
+	path = "reviews?reviewer_id=eq." + reviewer.ID
+	if err := supabase.patch(ctx, "rename_reviewer_reviews", path, fiber.Map{"reviewer_name": reviewer.Name()}, &renamed); err != nil {
+		slog.ErrorContext(ctx, "renaming reviews failed", "reviewer_id", reviewer.ID, "err", err)
+		return problem.Upstream("Your handle was saved but your reviews still show the old name, try again")
+	}
+	invalidated := map[int]bool{}
+	for _, review := range renamed {
+		if isPublic(&review) {
+			emitWebhook(ctx, webhook.ReviewUpdated, review)
+		}
+		if !invalidated[review.ProfessorID] {
+			invalidated[review.ProfessorID] = true
+			invalidateCache("reviews:" + strconv.Itoa(review.ProfessorID))
+		}
+	}
+
+	me.Reviewer = &reviewer
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(me)
+}
+
+// backfillReviewers gives reviews written before reviewer identities
+// existed their author's reviewer, one author at a time. It runs in the
+// background at startup, and stops early when ctx is done; every replica
+// may run it, the writes are idempotent. Anonymized reviews (account.go)
+// keep no reviewer.
+func backfillReviewers(ctx context.Context) {
+	filled := 0
+	for ctx.Err() == nil {
+		// A user under way is finished at shutdown
+		step := context.WithoutCancel(ctx)
+		var page []struct {
+			UserEmail string `json:"user_email"`
+		}
+		path := "reviews?reviewer_id=is.null&user_email=not.like.deleted:*&select=user_email&limit=1"
+		if err := supabase.get(step, "list_unattributed_reviews", path, &page); err != nil {
+			slog.WarnContext(ctx, "reviewer backfill stopped", "filled", filled, "err", err)
+			return
+		}
+		if len(page) == 0 {
+			if filled > 0 {
+				slog.InfoContext(ctx, "attributed existing reviews to reviewers", "reviews", filled)
+			}
+			return
+		}
+
+		email := page[0].UserEmail
+		reviewer, err := reviewerFor(step, email)
+		var updated []Review
+		if err == nil {
+			path := "reviews?user_email=eq." + url.QueryEscape(email) + "&reviewer_id=is.null&select=id"
+			err = supabase.patch(step, "attribute_reviews", path,
+				fiber.Map{"reviewer_id": reviewer.ID, "reviewer_name": reviewer.Name()}, &updated)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "reviewer backfill stopped", "filled", filled, "err", err)
+			return
+		}
+		if len(updated) == 0 {
+			// Another replica got there first
+			return
+		}
+		filled += len(updated)
+	}
+}
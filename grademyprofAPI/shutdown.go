@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Writing the leftovers to pending_stats gets its own budget, since it only
+// runs once the drain budget (config.StatsDrainTimeout) is spent.
+const persistTimeout = 3 * time.Second
+
+// workGroup tracks background stats recomputations so shutdown can wait for
+// them, and knows which professors still need one if it can't.
+type workGroup struct {
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	pending map[string]int // professor ID -> queued or running recomputations
+}
+
+var statsWork = &workGroup{pending: make(map[string]int)}
+
+// Go runs fn in the background on behalf of professorID.
+func (g *workGroup) Go(professorID string, fn func()) {
+	g.mu.Lock()
+	g.pending[professorID]++
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			g.mu.Lock()
+			if g.pending[professorID]--; g.pending[professorID] == 0 {
+				delete(g.pending, professorID)
+			}
+			g.mu.Unlock()
+		}()
+		fn()
+	}()
+}
+
+// Pending lists professors whose recomputation hasn't finished, sorted.
+func (g *workGroup) Pending() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ids := make([]string, 0, len(g.pending))
+	for id := range g.pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Wait blocks until all work is done or ctx expires.
+func (g *workGroup) Wait(ctx context.Context) error {
+	return waitGroup(ctx, &g.wg)
+}
+
+// background tracks the long-running jobs started with goBackground.
+var background sync.WaitGroup
+
+// goBackground runs job (e.g. deliverWebhooks) until ctx, the shutdown
+// signal, is done; shutdown waits for it to return.
+func goBackground(ctx context.Context, job func(context.Context)) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		job(ctx)
+	}()
+}
+
+// waitGroup blocks until wg is done or ctx expires.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// listenAndServe runs app until ctx is done (SIGINT or SIGTERM), then shuts
+// down: stop accepting connections and let in-flight requests finish, let
+// background jobs finish what they're doing and queued stats
+// recomputations finish, and write any of those that didn't to
+// pending_stats for the next instance to pick up (see resumePendingStats).
+func listenAndServe(ctx context.Context, app *fiber.App, addr string) error {
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(addr)
+	}()
+
+	select {
+	case err := <-listenErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "shutdown_timeout", cfg.ShutdownTimeout, "stats_drain_timeout", cfg.StatsDrainTimeout)
+
+	httpCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(httpCtx); err != nil {
+		slog.Warn("requests still in flight after shutdown timeout", "err", err)
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.StatsDrainTimeout)
+	defer cancel()
+	// The jobs were stopped along with ctx; stats recomputations they
+	// queued on the way out are waited for below
+	if err := waitGroup(drainCtx, &background); err != nil {
+		slog.Warn("background jobs still running at exit", "err", err)
+	}
+	if err := statsWork.Wait(drainCtx); err == nil {
+		slog.Info("background work drained")
+		return nil
+	}
+
+	pending := statsWork.Pending()
+	persistCtx, cancel := context.WithTimeout(context.Background(), persistTimeout)
+	defer cancel()
+	if err := persistPendingStats(persistCtx, pending); err != nil {
+		slog.Error("failed to persist pending stats recomputations", "professor_ids", pending, "err", err)
+		return nil
+	}
+	slog.Warn("stats recomputations still running at exit, persisted for the next instance", "professor_ids", pending)
+	return nil
+}
+
+type pendingStat struct {
+	ProfessorID int `json:"professor_id"`
+}
+
+// persistPendingStats upserts professorIDs into pending_stats.
+func persistPendingStats(ctx context.Context, professorIDs []string) error {
+	if len(professorIDs) == 0 {
+		return nil
+	}
+
+	rows := make([]pendingStat, 0, len(professorIDs))
+	for _, id := range professorIDs {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		rows = append(rows, pendingStat{ProfessorID: n})
+	}
+	jsonData, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/rest/v1/pending_stats", supabase.URL)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("apikey", supabase.APIKey)
+	req.Header.Set("Authorization", "Bearer "+supabase.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "resolution=merge-duplicates")
+
+	resp, err := supabase.do("persist_pending_stats", req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 && resp.StatusCode != 200 {
+		return fmt.Errorf("supabase returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// resumePendingStats schedules the recomputations a previous instance
+// couldn't finish. Each pending_stats row is only cleared once its
+// recomputation succeeded, so one that fails or is cut short by another
+// shutdown is picked up again next time. A missing pending_stats table only
+// costs a warning.
+func resumePendingStats(ctx context.Context) {
+	var rows []pendingStat
+	if err := supabase.get(ctx, "list_pending_stats", "pending_stats?select=professor_id", &rows); err != nil {
+		slog.Warn("could not read pending stats recomputations", "err", err)
+		return
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	ids := make([]int, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ProfessorID
+	}
+
+	slog.Info("resuming stats recomputations left by the previous instance", "professor_ids", ids)
+	for _, id := range ids {
+		scheduleStatsRecompute(ctx, strconv.Itoa(id), clearPendingStat)
+	}
+}
+
+// clearPendingStat removes professorID's pending_stats row.
+func clearPendingStat(ctx context.Context, professorID string) {
+	var cleared []pendingStat
+	if err := supabase.remove(ctx, "clear_pending_stats", "pending_stats?professor_id=eq."+professorID+"&select=professor_id", &cleared); err != nil {
+		slog.WarnContext(ctx, "could not clear pending stats recomputation", "professor_id", professorID, "err", err)
+	}
+}
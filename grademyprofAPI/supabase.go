@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/logging"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errConflict is returned by writes that break a unique constraint.
+var errConflict = errors.New("supabase: conflicting row")
+
+// do sends a Supabase request and records its latency and outcome under
+// operation (e.g. "list_reviews"). Transport errors and 4xx/5xx responses
+// both count as errors. The request ID and trace context in req's context
+// are forwarded, and the call gets its own client span. The span records
+// the path only: query strings can hold user emails.
+func (s *SupabaseClient) do(operation string, req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "supabase "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("supabase.operation", operation),
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLPath(req.URL.Path),
+		),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+
+	start := time.Now()
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	elapsed := time.Since(start)
+	supabaseDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+
+	if err != nil {
+		supabaseErrors.WithLabelValues(operation).Inc()
+		// *url.Error repeats the URL, query string included
+		cause := err
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			cause = urlErr.Err
+		}
+		span.RecordError(cause)
+		span.SetStatus(codes.Error, "request failed")
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		supabaseErrors.WithLabelValues(operation).Inc()
+		span.SetStatus(codes.Error, "")
+	}
+	slog.DebugContext(ctx, "supabase request", "operation", operation, "status", resp.StatusCode, "duration", elapsed)
+	return resp, nil
+}
+
+// get fetches a Supabase REST resource (e.g. "professor?campus=eq.goa")
+// and decodes the JSON response into out. operation labels the call in metrics.
+func (s *SupabaseClient) get(ctx context.Context, operation, path string, out interface{}) error {
+	url := fmt.Sprintf("%s/rest/v1/%s", s.URL, path)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("apikey", s.APIKey)
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+
+	resp, err := s.do(operation, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != 200 {
+		slog.WarnContext(ctx, "supabase returned an error", "operation", operation, "status", resp.StatusCode, "body", string(body))
+		return fmt.Errorf("supabase returned status %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+// patch updates the rows matching path (e.g. "reviews?id=eq.3") with data
+// and decodes the updated rows into out.
+func (s *SupabaseClient) patch(ctx context.Context, operation, path string, data, out interface{}) error {
+	return s.write(ctx, operation, "PATCH", path, data, out)
+}
+
+// insert adds data (a row or a slice of rows) to table and decodes the
+// inserted rows into out.
+func (s *SupabaseClient) insert(ctx context.Context, operation, table string, data, out interface{}) error {
+	return s.write(ctx, operation, "POST", table, data, out)
+}
+
+// rpc calls the database function fn with args (its named parameters) and
+// decodes what it returns into out.
+func (s *SupabaseClient) rpc(ctx context.Context, operation, fn string, args, out interface{}) error {
+	return s.write(ctx, operation, "POST", "rpc/"+fn, args, out)
+}
+
+// remove deletes the rows matching path and decodes them into out.
+func (s *SupabaseClient) remove(ctx context.Context, operation, path string, out interface{}) error {
+	return s.write(ctx, operation, "DELETE", path, nil, out)
+}
+
+// write sends data (if any) with method and decodes the rows Supabase
+// returns.
+func (s *SupabaseClient) write(ctx context.Context, operation, method, path string, data, out interface{}) error {
+	var body io.Reader
+	if data != nil {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	url := fmt.Sprintf("%s/rest/v1/%s", s.URL, path)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("apikey", s.APIKey)
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+
+	resp, err := s.do(operation, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusConflict {
+		return fmt.Errorf("%w: %s", errConflict, respBody)
+	}
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		slog.WarnContext(ctx, "supabase returned an error", "operation", operation, "status", resp.StatusCode, "body", string(respBody))
+		return fmt.Errorf("supabase returned status %d", resp.StatusCode)
+	}
+
+	return json.Unmarshal(respBody, out)
+}
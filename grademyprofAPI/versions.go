@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiVersion is one generation of the API's request/response shapes,
+// mounted under /api/<Name>. To change a shape, add a new version with its
+// own Register and mark the old one Deprecated, then Sunset.
+type apiVersion struct {
+	Name     string
+	Register func(router fiber.Router)
+
+	// Deprecated and Sunset drive the Deprecation (RFC 9745) and Sunset
+	// (RFC 8594) response headers; zero values leave them out.
+	Deprecated time.Time
+	Sunset     time.Time
+	// Successor is the version clients should move to.
+	Successor string
+
+	requests atomic.Uint64
+}
+
+// apiVersions lists every mounted version, oldest first.
+var apiVersions = []*apiVersion{
+	{Name: "v1", Register: registerV1},
+}
+
+// unversioned serves the original /api/... paths with v1 handlers until
+// every client has moved to /api/v1.
+var unversioned = &apiVersion{
+	Name:       "unversioned",
+	Deprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+	Successor:  "v1",
+}
+
+func registerV1(router fiber.Router) {
+	router.Get("/professors", conditionalGet, cacheResponse, getProfessors)
+	router.Get("/professors/compare", compareProfessors)
+	router.Get("/professors/:id", conditionalGet, cacheResponse, getProfessor)
+	router.Get("/professors/:id/reviews", conditionalGet, cacheResponse, getReviews)
+	router.Post("/professors/:id/reviews", middleware.AuthMiddleware, reviewCreateLimiter, createReview)
+	router.Patch("/professors/:id/reviews/:reviewId", middleware.AuthMiddleware, reviewUpdateLimiter, updateReview)
+	router.Delete("/professors/:id/reviews/:reviewId", middleware.AuthMiddleware, deleteReview)
+	router.Get("/professors/:id/user-review", middleware.AuthMiddleware, checkExistingReview)
+	router.Get("/campuses", getCampuses)
+	router.Get("/campuses/:campus/departments/:dept", getDepartment)
+	router.Get("/moderation/reviews", middleware.AuthMiddleware, middleware.RequireModerator, getModerationQueue)
+	router.Post("/moderation/reviews/:reviewId/approve", middleware.AuthMiddleware, middleware.RequireModerator, approveReview)
+	router.Post("/moderation/reviews/:reviewId/reject", middleware.AuthMiddleware, middleware.RequireModerator, rejectReview)
+	router.Get("/reviews/:reviewId/history", middleware.AuthMiddleware, middleware.RequireModerator, getReviewHistory)
+	router.Post("/reviews/:reviewId/restore", middleware.AuthMiddleware, restoreReview)
+	router.Get("/me", middleware.AuthMiddleware, getMe)
+	router.Patch("/me", middleware.AuthMiddleware, updateMe)
+	router.Get("/me/reviews", middleware.AuthMiddleware, getMyReviews)
+	router.Get("/me/export", middleware.AuthMiddleware, exportMyData)
+	router.Delete("/me", middleware.AuthMiddleware, deleteMe)
+	router.Get("/admin/audit", middleware.AuthMiddleware, middleware.RequireAdmin, getAuditLog)
+	router.Get("/admin/webhooks", middleware.AuthMiddleware, middleware.RequireAdmin, getWebhooks)
+	router.Post("/admin/webhooks", middleware.AuthMiddleware, middleware.RequireAdmin, createWebhook)
+	router.Patch("/admin/webhooks/:webhookId", middleware.AuthMiddleware, middleware.RequireAdmin, updateWebhook)
+	router.Delete("/admin/webhooks/:webhookId", middleware.AuthMiddleware, middleware.RequireAdmin, deleteWebhook)
+	router.Get("/admin/webhooks/:webhookId/deliveries", middleware.AuthMiddleware, middleware.RequireAdmin, getWebhookDeliveries)
+	router.Post("/admin/webhooks/:webhookId/deliveries/:deliveryId/replay", middleware.AuthMiddleware, middleware.RequireAdmin, replayWebhookDelivery)
+}
+
+var versionSegment = regexp.MustCompile(`^v\d+$`)
+
+// mountVersions registers every API version plus the unversioned alias.
+func mountVersions(app *fiber.App) {
+	app.Get("/api/versions", getVersions)
+
+	app.Use("/api", func(c *fiber.Ctx) error {
+		rest := strings.TrimPrefix(c.Path(), "/api")
+		segment := strings.SplitN(strings.TrimPrefix(rest, "/"), "/", 2)[0]
+		if versionSegment.MatchString(segment) || segment == "versions" {
+			return c.Next()
+		}
+
+		unversioned.annotate(c, rest)
+		c.Locals("api_alias", true)
+		c.Path("/api/" + unversioned.Successor + rest)
+		return c.Next()
+	})
+
+	for _, v := range apiVersions {
+		v := v
+		router := app.Group("/api/"+v.Name, func(c *fiber.Ctx) error {
+			// Aliased requests were already counted under the alias
+			if c.Locals("api_alias") == nil {
+				v.annotate(c, strings.TrimPrefix(c.Path(), "/api/"+v.Name))
+			}
+			c.Set("API-Version", v.Name)
+			return c.Next()
+		})
+		v.Register(router)
+	}
+}
+
+// annotate counts the request and adds deprecation headers. rest is the
+// path below the version prefix.
+func (v *apiVersion) annotate(c *fiber.Ctx, rest string) {
+	v.requests.Add(1)
+	versionRequests.WithLabelValues(v.Name).Inc()
+
+	if !v.Deprecated.IsZero() {
+		c.Set("Deprecation", fmt.Sprintf("@%d", v.Deprecated.Unix()))
+		if v.Successor != "" {
+			c.Append(fiber.HeaderLink, fmt.Sprintf(`</api/%s%s>; rel="successor-version"`, v.Successor, rest))
+		}
+	}
+	if !v.Sunset.IsZero() {
+		c.Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+	}
+}
+
+type versionInfo struct {
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Status     string     `json:"status"`
+	Deprecated *time.Time `json:"deprecated_at"`
+	Sunset     *time.Time `json:"sunset_at"`
+	Successor  string     `json:"successor,omitempty"`
+	Requests   uint64     `json:"requests"`
+}
+
+func (v *apiVersion) info(prefix string) versionInfo {
+	info := versionInfo{
+		Name:      v.Name,
+		Prefix:    prefix,
+		Status:    "current",
+		Successor: v.Successor,
+		Requests:  v.requests.Load(),
+	}
+	if !v.Deprecated.IsZero() {
+		info.Status = "deprecated"
+		info.Deprecated = &v.Deprecated
+	}
+	if !v.Sunset.IsZero() {
+		info.Sunset = &v.Sunset
+	}
+	return info
+}
+
+// getVersions lists the mounted versions with how many requests each has
+// served since startup, to tell when a deprecated one can be retired.
+func getVersions(c *fiber.Ctx) error {
+	versions := []versionInfo{unversioned.info("/api")}
+	for _, v := range apiVersions {
+		versions = append(versions, v.info("/api/"+v.Name))
+	}
+	return c.JSON(versions)
+}
@@ -0,0 +1,139 @@
+// Package webhook signs the webhook deliveries grademyprofAPI sends and
+// verifies them on the receiving end.
+//
+// Each delivery is a POST of a JSON Event. The SignatureHeader holds the
+// time it was signed and an HMAC-SHA256 of "<unix time>.<body>" keyed with
+// the subscription's secret:
+//
+//	X-GradeMyProf-Signature: t=1760000000,v1=5257a869e7ecebeda32affa62cdca3fa51cad7e77a0e56ff536d0ce8e108d8bd
+//
+// Receivers should check it with Verify and drop events whose ID they've
+// already handled: failed deliveries are retried and can be replayed.
+package webhook
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-GradeMyProf-Signature"
+	EventHeader     = "X-GradeMyProf-Event"
+	DeliveryHeader  = "X-GradeMyProf-Delivery"
+)
+
+// Event types. Subscribers filter on these, so never rename or reuse one;
+// add a new type instead.
+const (
+	ReviewCreated         = "review.created"
+	ReviewUpdated         = "review.updated"
+	ReviewDeleted         = "review.deleted"
+	ProfessorStatsChanged = "professor.stats_changed"
+)
+
+// Events lists every event type.
+var Events = []string{ReviewCreated, ReviewUpdated, ReviewDeleted, ProfessorStatsChanged}
+
+// Event is the body of a delivery. ID is the same for every delivery of
+// one event, retries and replays included.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sign returns the SignatureHeader value for body signed with secret at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + mac(secret, timestamp, body)
+}
+
+func mac(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var (
+	ErrMalformedSignature = errors.New("webhook: malformed signature header")
+	ErrBadSignature       = errors.New("webhook: signature doesn't match")
+	ErrStaleSignature     = errors.New("webhook: signature too old")
+)
+
+// Verify checks header, a SignatureHeader value, against body and secret.
+// Signatures older than tolerance are refused so a captured delivery can't
+// be sent again later; zero disables the check.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformedSignature
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrMalformedSignature
+	}
+
+	expected := mac(secret, timestamp, body)
+	matched := false
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			matched = true
+		}
+	}
+	if !matched {
+		return ErrBadSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return ErrStaleSignature
+	}
+	return nil
+}
+
+// maxBackoff caps the wait between two attempts.
+const maxBackoff = 6 * time.Hour
+
+// Backoff is how long to wait after the attempt-th failed attempt: base,
+// then doubling, capped at six hours, with up to 20% jitter either way so
+// retries from one outage don't arrive together.
+func Backoff(attempt int, base time.Duration) time.Duration {
+	wait := maxBackoff
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt <= 30 {
+		wait = min(base<<(attempt-1), maxBackoff)
+	}
+	jitter := (rand.Float64()*0.4 - 0.2) * float64(wait)
+	return wait + time.Duration(jitter)
+}
+
+// NewSecret returns a random subscription secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
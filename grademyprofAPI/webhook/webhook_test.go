@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1","type":"review.created"}`)
+	now := time.Now()
+	signed := Sign("whsec_test", now, body)
+	stale := Sign("whsec_test", now.Add(-10*time.Minute), body)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		header    string
+		body      []byte
+		tolerance time.Duration
+		want      error
+	}{
+		{"valid", "whsec_test", signed, body, 5 * time.Minute, nil},
+		{"rotated secret, one signature matches", "whsec_test", signed + ",v1=deadbeef", body, 5 * time.Minute, nil},
+		{"wrong secret", "whsec_other", signed, body, 5 * time.Minute, ErrBadSignature},
+		{"changed body", "whsec_test", signed, []byte(`{"id":"2"}`), 5 * time.Minute, ErrBadSignature},
+		{"too old", "whsec_test", stale, body, 5 * time.Minute, ErrStaleSignature},
+		{"age not checked", "whsec_test", stale, body, 0, nil},
+		{"no signature", "whsec_test", "t=" + timestamp, body, 0, ErrMalformedSignature},
+		{"no timestamp", "whsec_test", strings.Split(signed, ",")[1], body, 0, ErrMalformedSignature},
+		{"garbage", "whsec_test", "signed", body, 0, ErrMalformedSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.body, tt.tolerance); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{12, maxBackoff},
+		{100, maxBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempt), func(t *testing.T) {
+			// Up to 20% jitter either way
+			low, high := tt.want*8/10, tt.want*12/10
+			for range 100 {
+				if got := Backoff(tt.attempt, 30*time.Second); got < low || got > high {
+					t.Fatalf("Backoff(%d) = %s, want between %s and %s", tt.attempt, got, low, high)
+				}
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(a, "whsec_") || len(a) != len("whsec_")+64 || a == b {
+		t.Errorf("NewSecret() = %q then %q, want distinct whsec_ and 64 hex characters", a, b)
+	}
+}
@@ -0,0 +1,767 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Koifish2004/ProfessorWeb/fiberkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/Koifish2004/ProfessorWeb/webhook"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Admins subscribe URLs to events (see the webhook package) through
+// /api/admin/webhooks. An event is queued as one webhook_deliveries row
+// per subscriber (migrations/014_webhooks.sql) and sent by deliverWebhooks
+// in the background, so a slow receiver never holds up the request behind
+// the event and queued deliveries survive a restart. Failed deliveries are
+// retried with exponential backoff until WEBHOOK_MAX_ATTEMPTS. The rows are
+// the delivery log admins read, and any delivery can be replayed.
+
+// Values of webhook_deliveries.status.
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+const (
+	// How often deliverWebhooks looks for retries that have come due
+	webhookPollInterval = 10 * time.Second
+	// Deliveries sent at once
+	webhookBatchSize = 20
+	// Caps how many deliveries one request lists
+	maxDeliveryPage = 200
+)
+
+// WebhookSubscription is a URL subscribed to events. Secret signs its
+// deliveries; it's only shown once, when the subscription is created.
+type WebhookSubscription struct {
+	ID          int64    `json:"id"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+	Active      bool     `json:"active"`
+	CreatedBy   string   `json:"created_by"`
+	CreatedAt   string   `json:"created_at"`
+	Secret      string   `json:"secret,omitempty"`
+}
+
+// auditSnapshot is the subscription as the audit log keeps it: without
+// the secret, and with its creator pseudonymized.
+func (s WebhookSubscription) auditSnapshot() json.RawMessage {
+	s.CreatedBy, s.Secret = audit.Pseudonym(s.CreatedBy), ""
+	return audit.Snapshot(s)
+}
+
+// subscriptionColumns are what admins see of a subscription: all but the secret.
+const subscriptionColumns = "id,url,events,description,active,created_by,created_at"
+
+// WebhookDelivery is one event sent, or to be sent, to one subscription.
+// Payload is the body, a webhook.Event.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *string         `json:"next_attempt_at"`
+	LastAttemptAt  *string         `json:"last_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      string          `json:"created_at"`
+	DeliveredAt    *string         `json:"delivered_at"`
+	ReplayOf       *int64          `json:"replay_of"`
+}
+
+// ProfessorStats are the aggregates professor.stats_changed reports.
+type ProfessorStats struct {
+	AverageRating         float64 `json:"average_rating"`
+	ReviewCount           int     `json:"review_count"`
+	AverageDifficulty     float64 `json:"average_difficulty"`
+	WouldTakeAgainPercent int     `json:"would_take_again_percent"`
+}
+
+// ProfessorStatsChange is the data of professor.stats_changed. Before is
+// missing when the old stats couldn't be read.
+type ProfessorStatsChange struct {
+	ProfessorID int             `json:"professor_id"`
+	Before      *ProfessorStats `json:"before,omitempty"`
+	After       ProfessorStats  `json:"after"`
+}
+
+// webhookKick wakes deliverWebhooks when an event is queued.
+var webhookKick = make(chan struct{}, 1)
+
+func kickWebhooks() {
+	select {
+	case webhookKick <- struct{}{}:
+	default:
+	}
+}
+
+// emitWebhook queues an eventType event carrying data for every active
+// subscription to it. The change behind the event has already happened, so
+// failures are logged and counted, not returned.
+func emitWebhook(ctx context.Context, eventType string, data interface{}) {
+	// A client hanging up mustn't lose the event
+	ctx = context.WithoutCancel(ctx)
+	event := webhook.Event{ID: utils.UUIDv4(), Type: eventType, CreatedAt: time.Now().UTC()}
+
+	err := func() error {
+		var subscriptions []WebhookSubscription
+		path := "webhook_subscriptions?active=is.true&events=cs." + url.QueryEscape("{"+eventType+"}") + "&select=id"
+		if err := supabaseService.get(ctx, "find_webhook_subscriptions", path, &subscriptions); err != nil {
+			return err
+		}
+		if len(subscriptions) == 0 {
+			return nil
+		}
+
+		var err error
+		if event.Data, err = json.Marshal(data); err != nil {
+			return err
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		rows := make([]fiber.Map, len(subscriptions))
+		for i, subscription := range subscriptions {
+			rows[i] = fiber.Map{
+				"subscription_id": subscription.ID,
+				"event_id":        event.ID,
+				"event":           eventType,
+				"payload":         json.RawMessage(payload),
+				"status":          deliveryPending,
+				"next_attempt_at": now,
+			}
+		}
+		var queued []WebhookDelivery
+		return supabaseService.insert(ctx, "queue_webhook_deliveries", "webhook_deliveries?select=id", rows, &queued)
+	}()
+	if err != nil {
+		webhookQueueFailures.Inc()
+		slog.ErrorContext(ctx, "webhook event not queued", "event", eventType, "event_id", event.ID, "err", err)
+		return
+	}
+	kickWebhooks()
+}
+
+// isPublic reports whether review shows in listings: approved and not
+// deleted. A nil review isn't public.
+func isPublic(review *Review) bool {
+	return review != nil && review.ModerationStatus == statusApproved && review.DeletedAt == ""
+}
+
+// emitReviewChange sends the event for a review going from before to after
+// (nil when it didn't exist or is gone) as the public sees it: a review
+// entering listings is review.created, one changing while listed
+// review.updated, one leaving them review.deleted. Held reviews stay
+// unannounced until a moderator approves them.
+func emitReviewChange(ctx context.Context, before, after *Review) {
+	switch was, is := isPublic(before), isPublic(after); {
+	case !was && is:
+		emitWebhook(ctx, webhook.ReviewCreated, after)
+	case was && is:
+		emitWebhook(ctx, webhook.ReviewUpdated, after)
+	case was && !is:
+		// Deleted or rejected: send the review as it is now, if it still exists
+		if after != nil {
+			before = after
+		}
+		emitWebhook(ctx, webhook.ReviewDeleted, before)
+	}
+}
+
+// deliverWebhooks sends deliveries as they come due until ctx ends. It
+// wakes up when an event is queued, and every webhookPollInterval for
+// retries. Every replica runs it: a delivery is claimed before it's sent,
+// so only one replica sends each attempt.
+func deliverWebhooks(ctx context.Context) {
+	// Checked again as the connection is made: the subscribed host may
+	// resolve somewhere else by now. No proxy, it would be dialed instead.
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !cfg.WebhookAllowPrivate {
+		dialer.Control = refusePrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.WebhookTimeout,
+		// The signature is for the subscribed URL: a redirect is a failure
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for ctx.Err() == nil {
+		// A batch under way is finished at shutdown: its deliveries are
+		// already claimed
+		sent, err := sendDueWebhooks(context.WithoutCancel(ctx), client)
+		if err != nil {
+			slog.WarnContext(ctx, "fetching due webhook deliveries failed", "err", err)
+		}
+		if sent == webhookBatchSize {
+			// There may be more waiting
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-webhookKick:
+		case <-time.After(webhookPollInterval):
+		}
+	}
+}
+
+// sendDueWebhooks sends a batch of due deliveries at once and returns how
+// many it found.
+func sendDueWebhooks(ctx context.Context, client *http.Client) (int, error) {
+	var due []WebhookDelivery
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	path := fmt.Sprintf("webhook_deliveries?status=eq.%s&next_attempt_at=lte.%s&order=next_attempt_at.asc&limit=%d",
+		deliveryPending, url.QueryEscape(now), webhookBatchSize)
+	if err := supabaseService.get(ctx, "list_due_webhook_deliveries", path, &due); err != nil {
+		return 0, err
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	var ids []string
+	for _, delivery := range due {
+		id := strconv.FormatInt(delivery.SubscriptionID, 10)
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	var subscriptions []WebhookSubscription
+	path = "webhook_subscriptions?id=in.(" + strings.Join(ids, ",") + ")&select=id,url,secret,active"
+	if err := supabaseService.get(ctx, "find_webhook_subscriptions", path, &subscriptions); err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range due {
+		i := slices.IndexFunc(subscriptions, func(s WebhookSubscription) bool { return s.ID == delivery.SubscriptionID })
+		if i < 0 || !subscriptions[i].Active {
+			// Disabled since the event: replay it once the webhook is back on
+			settleDelivery(ctx, delivery.ID, delivery.Attempts, fiber.Map{
+				"status":          deliveryFailed,
+				"next_attempt_at": nil,
+				"last_error":      "The webhook is disabled",
+			})
+			webhookDeliveries.WithLabelValues(deliveryFailed).Inc()
+			continue
+		}
+		wg.Add(1)
+		go func(subscription WebhookSubscription) {
+			defer wg.Done()
+			attemptDelivery(ctx, client, subscription, delivery)
+		}(subscriptions[i])
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// attemptDelivery claims delivery and sends it once, then records how it
+// went: delivered, due again after a backoff, or failed for good after
+// WEBHOOK_MAX_ATTEMPTS.
+func attemptDelivery(ctx context.Context, client *http.Client, subscription WebhookSubscription, delivery WebhookDelivery) {
+	attempt := delivery.Attempts + 1
+	start := time.Now().UTC()
+
+	// Another replica that got here first has already counted the attempt.
+	// Should this one die mid-send, the delivery comes due again once the
+	// send would have timed out.
+	var claimed []WebhookDelivery
+	path := fmt.Sprintf("webhook_deliveries?id=eq.%d&status=eq.%s&attempts=eq.%d&select=id", delivery.ID, deliveryPending, delivery.Attempts)
+	err := supabaseService.patch(ctx, "claim_webhook_delivery", path, fiber.Map{
+		"attempts":        attempt,
+		"last_attempt_at": start.Format(time.RFC3339Nano),
+		"next_attempt_at": start.Add(2 * cfg.WebhookTimeout).Format(time.RFC3339Nano),
+	}, &claimed)
+	if err != nil {
+		slog.WarnContext(ctx, "claiming webhook delivery failed", "delivery_id", delivery.ID, "err", err)
+		return
+	}
+	if len(claimed) == 0 {
+		return
+	}
+
+	status, err := postWebhook(ctx, client, subscription, delivery)
+	result := fiber.Map{"last_status_code": nil, "last_error": nil}
+	if status != 0 {
+		result["last_status_code"] = status
+	}
+
+	outcome := deliveryDelivered
+	switch {
+	case err == nil:
+		result["status"] = deliveryDelivered
+		result["delivered_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+		result["next_attempt_at"] = nil
+	case attempt < cfg.WebhookMaxAttempts:
+		outcome = "retried"
+		result["last_error"] = err.Error()
+		result["next_attempt_at"] = time.Now().Add(webhook.Backoff(attempt, cfg.WebhookRetryBase)).UTC().Format(time.RFC3339Nano)
+	default:
+		outcome = deliveryFailed
+		result["status"] = deliveryFailed
+		result["last_error"] = err.Error()
+		result["next_attempt_at"] = nil
+		slog.WarnContext(ctx, "webhook delivery failed for good", "delivery_id", delivery.ID,
+			"subscription_id", subscription.ID, "event", delivery.Event, "attempts", attempt, "err", err)
+	}
+	webhookDeliveries.WithLabelValues(outcome).Inc()
+	webhookDuration.Observe(time.Since(start).Seconds())
+	settleDelivery(ctx, delivery.ID, attempt, result)
+}
+
+// settleDelivery records the outcome of a delivery's attempts-th attempt.
+func settleDelivery(ctx context.Context, id int64, attempts int, result fiber.Map) {
+	var settled []WebhookDelivery
+	path := fmt.Sprintf("webhook_deliveries?id=eq.%d&attempts=eq.%d&select=id", id, attempts)
+	if err := supabaseService.patch(ctx, "settle_webhook_delivery", path, result, &settled); err != nil {
+		// Still pending: it's sent again when the claim runs out
+		slog.ErrorContext(ctx, "recording webhook delivery failed", "delivery_id", id, "err", err)
+	}
+}
+
+// postWebhook sends delivery to subscription, signed, and returns the
+// receiver's status code (0 if it didn't answer). Anything but a 2xx is an
+// error.
+func postWebhook(ctx context.Context, client *http.Client, subscription WebhookSubscription, delivery WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderUserAgent, "GradeMyProf-Webhooks/1.0")
+	req.Header.Set(webhook.EventHeader, delivery.Event)
+	req.Header.Set(webhook.DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(subscription.Secret, time.Now(), delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// *url.Error repeats the method and URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookID parses the :webhookId route parameter.
+func webhookID(c *fiber.Ctx) (int64, error) {
+	id, err := strconv.ParseInt(c.Params("webhookId"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, problem.BadRequest(problem.CodeInvalidParameter, "Webhook ID must be a number")
+	}
+	return id, nil
+}
+
+// findWebhook returns the subscription with id, or nil if there's none.
+func findWebhook(ctx context.Context, id int64) (*WebhookSubscription, error) {
+	var subscriptions []WebhookSubscription
+	path := fmt.Sprintf("webhook_subscriptions?id=eq.%d&select=%s", id, subscriptionColumns)
+	if err := supabaseService.get(ctx, "find_webhook", path, &subscriptions); err != nil {
+		return nil, err
+	}
+	if len(subscriptions) == 0 {
+		return nil, nil
+	}
+	return &subscriptions[0], nil
+}
+
+// checkWebhookURL accepts absolute http(s) URLs whose host only resolves
+// to public addresses, unless WEBHOOK_ALLOW_PRIVATE is set.
+func checkWebhookURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return problem.BadRequest(problem.CodeInvalidParameter, "url must be an http(s) URL")
+	}
+	if cfg.WebhookAllowPrivate {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return problem.BadRequest(problem.CodeInvalidParameter, "url host doesn't resolve")
+	}
+	for _, addr := range addrs {
+		if !isPublicAddress(addr) {
+			return problem.BadRequest(problem.CodeInvalidParameter, "url must not point at a private, loopback or link-local address")
+		}
+	}
+	return nil
+}
+
+// nonPublicPrefixes are ranges that aren't reachable on the internet but
+// that netip doesn't classify as private, loopback or link-local.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, may embed any IPv4
+}
+
+// isPublicAddress reports whether deliveries may go to addr: not private,
+// loopback, link-local (cloud metadata services live there), multicast or
+// otherwise reserved.
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// errPrivateAddress fails deliveries to hosts that resolve to addresses
+// isPublicAddress refuses.
+var errPrivateAddress = errors.New("refusing to connect to a private, loopback or link-local address")
+
+// refusePrivateAddress is a net.Dialer Control that refuses connections to
+// addresses isPublicAddress refuses, after DNS resolution.
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddress(addr) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// checkWebhookEvents accepts a non-empty list of known events, and drops
+// repeats.
+func checkWebhookEvents(events []string) ([]string, error) {
+	var unique []string
+	for _, event := range events {
+		if !slices.Contains(webhook.Events, event) {
+			return nil, problem.BadRequest(problem.CodeInvalidParameter, "events must be some of "+strings.Join(webhook.Events, ", ")).
+				WithDetails(fiber.Map{"event": event})
+		}
+		if !slices.Contains(unique, event) {
+			unique = append(unique, event)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, problem.BadRequest(problem.CodeInvalidParameter, "events must name at least one event")
+	}
+	return unique, nil
+}
+
+// checkWebhookDescription caps descriptions at 200 characters.
+func checkWebhookDescription(description string) error {
+	if utf8.RuneCountInString(description) > 200 {
+		return problem.BadRequest(problem.CodeInvalidParameter, "description must be at most 200 characters")
+	}
+	return nil
+}
+
+// getWebhooks lists the webhook subscriptions, oldest first.
+func getWebhooks(c *fiber.Ctx) error {
+	subscriptions := []WebhookSubscription{}
+	path := "webhook_subscriptions?select=" + subscriptionColumns + "&order=id.asc"
+	if err := supabaseService.get(c.UserContext(), "list_webhooks", path, &subscriptions); err != nil {
+		return problem.Upstream("Failed to fetch webhooks")
+	}
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(subscriptions)
+}
+
+// createWebhook subscribes a URL to events. The response is the only time
+// the signing secret is shown.
+func createWebhook(c *fiber.Ctx) error {
+	var input struct {
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		Description string   `json:"description"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return problem.BadRequest(problem.CodeInvalidBody, "Invalid request body")
+	}
+	if err := checkWebhookURL(c.UserContext(), input.URL); err != nil {
+		return err
+	}
+	events, err := checkWebhookEvents(input.Events)
+	if err != nil {
+		return err
+	}
+	if err := checkWebhookDescription(input.Description); err != nil {
+		return err
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return problem.Internal("Failed to create webhook")
+	}
+	email, _ := c.Locals("user_email").(string)
+
+	var created []WebhookSubscription
+	err = supabaseService.insert(c.UserContext(), "create_webhook", "webhook_subscriptions?select="+subscriptionColumns, fiber.Map{
+		"url":         input.URL,
+		"events":      events,
+		"secret":      secret,
+		"description": input.Description,
+		"active":      true,
+		"created_by":  email,
+		"created_at":  time.Now().UTC().Format(time.RFC3339Nano),
+	}, &created)
+	if err != nil || len(created) == 0 {
+		return problem.Upstream("Failed to create webhook")
+	}
+	subscription := created[0]
+
+	entry := fiberkit.AuditEntry(c, audit.ActionWebhookCreate, "webhook", strconv.FormatInt(subscription.ID, 10))
+	entry.After = subscription.auditSnapshot()
+	recordAudit(c.UserContext(), entry)
+
+	subscription.Secret = secret
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.Status(fiber.StatusCreated).JSON(subscription)
+}
+
+// updateWebhook changes a subscription's URL, events or description, or
+// turns it off and on. Deliveries already queued go to the new URL.
+func updateWebhook(c *fiber.Ctx) error {
+	id, err := webhookID(c)
+	if err != nil {
+		return err
+	}
+	var input struct {
+		URL         *string   `json:"url"`
+		Events      *[]string `json:"events"`
+		Description *string   `json:"description"`
+		Active      *bool     `json:"active"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return problem.BadRequest(problem.CodeInvalidBody, "Invalid request body")
+	}
+
+	changes := fiber.Map{}
+	if input.URL != nil {
+		if err := checkWebhookURL(c.UserContext(), *input.URL); err != nil {
+			return err
+		}
+		changes["url"] = *input.URL
+	}
+	if input.Events != nil {
+		events, err := checkWebhookEvents(*input.Events)
+		if err != nil {
+			return err
+		}
+		changes["events"] = events
+	}
+	if input.Description != nil {
+		if err := checkWebhookDescription(*input.Description); err != nil {
+			return err
+		}
+		changes["description"] = *input.Description
+	}
+	if input.Active != nil {
+		changes["active"] = *input.Active
+	}
+	if len(changes) == 0 {
+		return problem.BadRequest(problem.CodeInvalidBody, "Nothing to update: send url, events, description or active")
+	}
+
+	ctx := c.UserContext()
+	current, err := findWebhook(ctx, id)
+	if err != nil {
+		return problem.Upstream("Failed to update webhook")
+	}
+	if current == nil {
+		return problem.NotFound("Webhook not found")
+	}
+
+	var updated []WebhookSubscription
+	path := fmt.Sprintf("webhook_subscriptions?id=eq.%d&select=%s", id, subscriptionColumns)
+	if err := supabaseService.patch(ctx, "update_webhook", path, changes, &updated); err != nil {
+		return problem.Upstream("Failed to update webhook")
+	}
+	if len(updated) == 0 {
+		return problem.NotFound("Webhook not found")
+	}
+
+	entry := fiberkit.AuditEntry(c, audit.ActionWebhookUpdate, "webhook", strconv.FormatInt(id, 10))
+	entry.Before, entry.After = current.auditSnapshot(), updated[0].auditSnapshot()
+	recordAudit(ctx, entry)
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(updated[0])
+}
+
+// deleteWebhook removes a subscription with its delivery log. Queued
+// deliveries are dropped.
+func deleteWebhook(c *fiber.Ctx) error {
+	id, err := webhookID(c)
+	if err != nil {
+		return err
+	}
+
+	var deleted []WebhookSubscription
+	path := fmt.Sprintf("webhook_subscriptions?id=eq.%d&select=%s", id, subscriptionColumns)
+	if err := supabaseService.remove(c.UserContext(), "delete_webhook", path, &deleted); err != nil {
+		return problem.Upstream("Failed to delete webhook")
+	}
+	if len(deleted) == 0 {
+		return problem.NotFound("Webhook not found")
+	}
+
+	entry := fiberkit.AuditEntry(c, audit.ActionWebhookDelete, "webhook", strconv.FormatInt(id, 10))
+	entry.Before = deleted[0].auditSnapshot()
+	recordAudit(c.UserContext(), entry)
+
+	return c.JSON(fiber.Map{"message": "Webhook deleted"})
+}
+
+// getWebhookDeliveries is a subscription's delivery log, newest first, a
+// page at a time: pass the last id seen as before= for the next page.
+func getWebhookDeliveries(c *fiber.Ctx) error {
+	id, err := webhookID(c)
+	if err != nil {
+		return err
+	}
+	query := []string{fmt.Sprintf("subscription_id=eq.%d", id), "order=id.desc"}
+
+	if status := c.Query("status"); status != "" {
+		if !slices.Contains([]string{deliveryPending, deliveryDelivered, deliveryFailed}, status) {
+			return problem.BadRequest(problem.CodeInvalidParameter, "status must be pending, delivered or failed")
+		}
+		query = append(query, "status=eq."+status)
+	}
+	if event := c.Query("event"); event != "" {
+		if !slices.Contains(webhook.Events, event) {
+			return problem.BadRequest(problem.CodeInvalidParameter, "event must be one of "+strings.Join(webhook.Events, ", "))
+		}
+		query = append(query, "event=eq."+event)
+	}
+	if before := c.Query("before"); before != "" {
+		cursor, err := strconv.ParseInt(before, 10, 64)
+		if err != nil || cursor < 1 {
+			return problem.BadRequest(problem.CodeInvalidParameter, "before must be a delivery ID")
+		}
+		query = append(query, fmt.Sprintf("id=lt.%d", cursor))
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > maxDeliveryPage {
+		return problem.BadRequest(problem.CodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", maxDeliveryPage))
+	}
+	query = append(query, fmt.Sprintf("limit=%d", limit))
+
+	ctx := c.UserContext()
+	subscription, err := findWebhook(ctx, id)
+	if err != nil {
+		return problem.Upstream("Failed to fetch deliveries")
+	}
+	if subscription == nil {
+		return problem.NotFound("Webhook not found")
+	}
+
+	deliveries := []WebhookDelivery{}
+	if err := supabaseService.get(ctx, "list_webhook_deliveries", "webhook_deliveries?"+strings.Join(query, "&"), &deliveries); err != nil {
+		return problem.Upstream("Failed to fetch deliveries")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(deliveries)
+}
+
+// replayWebhookDelivery queues a delivered or failed event again, as a new
+// delivery with the same event ID and body, signed afresh when it's sent.
+func replayWebhookDelivery(c *fiber.Ctx) error {
+	id, err := webhookID(c)
+	if err != nil {
+		return err
+	}
+	deliveryID, err := strconv.ParseInt(c.Params("deliveryId"), 10, 64)
+	if err != nil || deliveryID < 1 {
+		return problem.BadRequest(problem.CodeInvalidParameter, "Delivery ID must be a number")
+	}
+
+	ctx := c.UserContext()
+	subscription, err := findWebhook(ctx, id)
+	if err != nil {
+		return problem.Upstream("Failed to replay delivery")
+	}
+	if subscription == nil {
+		return problem.NotFound("Webhook not found")
+	}
+	if !subscription.Active {
+		return problem.New(fiber.StatusConflict, problem.CodeConflict, "The webhook is disabled, enable it first")
+	}
+
+	var original []WebhookDelivery
+	path := fmt.Sprintf("webhook_deliveries?id=eq.%d&subscription_id=eq.%d", deliveryID, id)
+	if err := supabaseService.get(ctx, "find_webhook_delivery", path, &original); err != nil {
+		return problem.Upstream("Failed to replay delivery")
+	}
+	if len(original) == 0 {
+		return problem.NotFound("Delivery not found")
+	}
+
+	var replayed []WebhookDelivery
+	err = supabaseService.insert(ctx, "replay_webhook_delivery", "webhook_deliveries", fiber.Map{
+		"subscription_id": id,
+		"event_id":        original[0].EventID,
+		"event":           original[0].Event,
+		"payload":         original[0].Payload,
+		"status":          deliveryPending,
+		"next_attempt_at": time.Now().UTC().Format(time.RFC3339Nano),
+		"replay_of":       deliveryID,
+	}, &replayed)
+	if err != nil || len(replayed) == 0 {
+		return problem.Upstream("Failed to replay delivery")
+	}
+	kickWebhooks()
+
+	entry := fiberkit.AuditEntry(c, audit.ActionWebhookReplay, "webhook_delivery", strconv.FormatInt(deliveryID, 10))
+	entry.After = audit.Snapshot(fiber.Map{"replay_id": replayed[0].ID, "subscription_id": id, "event_id": original[0].EventID})
+	recordAudit(ctx, entry)
+
+	return c.Status(fiber.StatusAccepted).JSON(replayed[0])
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/Koifish2004/ProfessorWeb/config"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.2.3", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"198.18.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:93.184.215.14", true},
+		{"64:ff9b::7f00:1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddress(%s) = %t, want %t", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefusePrivateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    error
+	}{
+		{"93.184.215.14:443", nil},
+		{"127.0.0.1:80", errPrivateAddress},
+		{"[::1]:443", errPrivateAddress},
+		{"169.254.169.254:80", errPrivateAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if err := refusePrivateAddress("tcp", tt.address, nil); !errors.Is(err, tt.want) {
+				t.Errorf("refusePrivateAddress(%s) = %v, want %v", tt.address, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	previous := cfg
+	t.Cleanup(func() { cfg = previous })
+
+	tests := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		ok           bool
+	}{
+		{"public", "https://93.184.215.14/hooks", false, true},
+		{"loopback", "http://127.0.0.1:8080/hooks", false, false},
+		{"IPv6 loopback", "http://[::1]/hooks", false, false},
+		{"metadata service", "http://169.254.169.254/latest/meta-data", false, false},
+		{"private allowed for local testing", "http://127.0.0.1:8080/hooks", true, true},
+		{"not http", "ftp://93.184.215.14/hooks", false, false},
+		{"relative", "/hooks", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = &config.Config{WebhookAllowPrivate: tt.allowPrivate}
+			if err := checkWebhookURL(context.Background(), tt.url); (err == nil) != tt.ok {
+				t.Errorf("checkWebhookURL(%s) = %v, want accepted: %t", tt.url, err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Package config loads grademyprofAuth's settings into a typed Config. Every
+// setting is an environment variable (see README.md), can also come from a
+// .env-style file passed with --config, and falls back to the default in
+// its tag. Run the service with --print-config to see the effective values.
+package config
+
+import (
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/envconfig"
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Port string `env:"PORT" default:"8080"`
+
+	// Signs and verifies the tokens we issue; grademyprofAPI never sees it
+	JWTSecret     string        `env:"JWT_SECRET" secret:"true"`
+	TokenLifetime time.Duration `env:"TOKEN_LIFETIME" default:"720h"`
+
+	// Firebase credentials: the key JSON itself (Railway) or a path to it
+	// (local development). The key wins when both are set.
+	FirebaseServiceAccountKey  string `env:"FIREBASE_SERVICE_ACCOUNT_KEY" secret:"true"`
+	FirebaseServiceAccountPath string `env:"FIREBASE_SERVICE_ACCOUNT_PATH"`
+
+	// Accounts whose tokens carry the moderator and admin roles
+	ModeratorEmails []string `env:"MODERATOR_EMAILS"`
+	AdminEmails     []string `env:"ADMIN_EMAILS"`
+
+	// Where logins and token revocations are audited: the audit_log table
+	// grademyprofAPI reads, which only the service role may write. Unset,
+	// entries only go to the log.
+	SupabaseURL            string `env:"SUPABASE_URL"`
+	SupabaseServiceRoleKey string `env:"SUPABASE_SERVICE_ROLE_KEY" secret:"true"`
+
+	// Keys the pseudonyms the audit log keeps instead of emails and IPs,
+	// the same value as grademyprofAPI's. Required with SUPABASE_URL.
+	AuditKey string `env:"AUDIT_KEY" secret:"true"`
+
+	// Bearer token Prometheus scrapes /metrics with. Unset, /metrics
+	// refuses every request.
+	MetricsToken string `env:"METRICS_TOKEN" secret:"true"`
+
+	// Local dev + Campus + Production
+	CORSOrigins []string `env:"CORS_ORIGINS" default:"http://localhost:5173,http://192.168.2.3,https://kaifn8n.online"`
+
+	// Addresses or CIDRs of the reverse proxies in front of the service,
+	// whose X-Forwarded-For is believed for the client IP. Unset, the
+	// header is ignored and the client IP is the connection's address.
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	// Logins per client IP allowed within the window
+	LoginRateLimit  int           `env:"LOGIN_RATE_LIMIT_MAX" default:"5"`
+	LoginRateWindow time.Duration `env:"LOGIN_RATE_LIMIT_WINDOW" default:"1m"`
+
+	// Where limiter counters live: "memory" (per replica) or "redis"
+	// (shared, at RedisURL)
+	RateLimitStore string `env:"RATE_LIMIT_STORE" default:"memory"`
+	RedisURL       string `env:"REDIS_URL" secret:"true"`
+
+	// Where revoked tokens are remembered: "memory" (per replica, lost on
+	// restart) or "redis" (shared, at RedisURL)
+	RevocationStore string `env:"REVOCATION_STORE" default:"memory"`
+
+	// How long in-flight logins get to finish after SIGTERM. Railway waits
+	// drainingSeconds (railway.json) before it kills the process.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"10s"`
+
+	LogLevel string `env:"LOG_LEVEL" default:"info"`
+}
+
+// Load reads the configuration from the environment and, when path is set,
+// from a .env-style file, then validates it. The error lists every problem.
+func Load(path string) (*Config, error) {
+	file := map[string]string{}
+	if path != "" {
+		read, err := godotenv.Read(path)
+		if err != nil {
+			return nil, err
+		}
+		file = read
+	}
+
+	cfg := &Config{}
+	errs := envconfig.Parse(cfg, file).Merge(cfg.validate())
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() envconfig.Errors {
+	var errs envconfig.Errors
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs.Addf("PORT: must be a port number, got %q", c.Port)
+	}
+	if c.JWTSecret == "" {
+		errs.Addf("JWT_SECRET: required")
+	}
+	if c.TokenLifetime <= 0 {
+		errs.Addf("TOKEN_LIFETIME: must be positive, got %s", c.TokenLifetime)
+	}
+	if c.FirebaseServiceAccountKey == "" && c.FirebaseServiceAccountPath == "" {
+		errs.Addf("FIREBASE_SERVICE_ACCOUNT_PATH: required unless FIREBASE_SERVICE_ACCOUNT_KEY is set")
+	}
+	if len(c.CORSOrigins) == 0 {
+		errs.Addf("CORS_ORIGINS: at least one origin is required")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs.Addf("TRUSTED_PROXIES: must be IP addresses or CIDRs, got %q", proxy)
+		}
+	}
+	if c.LoginRateLimit <= 0 {
+		errs.Addf("LOGIN_RATE_LIMIT_MAX: must be positive, got %d", c.LoginRateLimit)
+	}
+	if c.LoginRateWindow <= 0 {
+		errs.Addf("LOGIN_RATE_LIMIT_WINDOW: must be positive, got %s", c.LoginRateWindow)
+	}
+	needsRedis := ""
+	for _, store := range []struct{ key, value string }{
+		{"RATE_LIMIT_STORE", c.RateLimitStore},
+		{"REVOCATION_STORE", c.RevocationStore},
+	} {
+		switch store.value {
+		case "memory":
+		case "redis":
+			needsRedis = store.key
+		default:
+			errs.Addf("%s: must be memory or redis, got %q", store.key, store.value)
+		}
+	}
+	if needsRedis != "" {
+		if c.RedisURL == "" {
+			errs.Addf("REDIS_URL: required when %s is redis", needsRedis)
+		} else if u, err := url.Parse(c.RedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+			errs.Addf("REDIS_URL: must be a redis:// or rediss:// URL")
+		}
+	}
+	if c.SupabaseURL != "" {
+		if u, err := url.Parse(c.SupabaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.Addf("SUPABASE_URL: must be an http(s) URL, got %q", c.SupabaseURL)
+		}
+		if c.SupabaseServiceRoleKey == "" {
+			errs.Addf("SUPABASE_SERVICE_ROLE_KEY: required when SUPABASE_URL is set")
+		}
+		if c.AuditKey == "" {
+			errs.Addf("AUDIT_KEY: required when SUPABASE_URL is set")
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs.Addf("SHUTDOWN_TIMEOUT: must be positive, got %s", c.ShutdownTimeout)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		errs.Addf("LOG_LEVEL: must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	return errs
+}
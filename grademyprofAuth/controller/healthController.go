@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type dependencyCheck struct {
+	Name  string
+	Check func() error
+}
+
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+var readinessChecks = []dependencyCheck{
+	{Name: "jwt_secret", Check: checkJWTSecret},
+	{Name: "firebase", Check: middleware.FirebaseReady},
+}
+
+// Healthz is the liveness probe: the process is up and serving.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz is the readiness probe: tokens can be issued and verified. It
+// returns 503 with per-dependency results otherwise.
+func Readyz(c *gin.Context) {
+	results := make(map[string]checkResult, len(readinessChecks))
+	status, code := "ready", http.StatusOK
+
+	for _, check := range readinessChecks {
+		start := time.Now()
+		err := check.Check()
+		result := checkResult{
+			Status:    "ok",
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			// /readyz is public: the cause only goes to the log
+			slog.WarnContext(c.Request.Context(), "readiness check failed", "check", check.Name, "err", err)
+			result.Status = "error"
+			result.Error = "unavailable"
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+		results[check.Name] = result
+	}
+
+	c.JSON(code, gin.H{
+		"status": status,
+		"checks": results,
+	})
+}
+
+func checkJWTSecret() error {
+	if len(middleware.JWTSecret) == 0 {
+		return errors.New("JWT_SECRET not configured")
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package ginkit
+
+import (
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditEntry starts the entry for an action taken on a target in request
+// c. The actor is the signed-in user (RequireAuthHeader) unless set
+// afterwards. Pass account targets and actors through audit.Pseudonym.
+func AuditEntry(c *gin.Context, action audit.Action, targetType, targetID string) audit.Entry {
+	return audit.Entry{
+		Actor:      audit.Pseudonym(c.GetString("user_email")),
+		ActorRole:  c.GetString("user_role"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  c.GetString("request_id"),
+		IP:         audit.Pseudonym(c.ClientIP()),
+	}
+}
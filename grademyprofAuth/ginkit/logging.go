@@ -0,0 +1,36 @@
+package ginkit
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Logging runs after middleware.RequestID. It stores the request ID in
+// the request's context, so slog calls made with c.Request.Context() carry
+// it, and writes one access log record per request.
+func Logging(c *gin.Context) {
+	start := time.Now()
+
+	ctx := logging.WithRequestID(c.Request.Context(), c.GetString("request_id"))
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	level := slog.LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	slog.LogAttrs(ctx, level, "request",
+		slog.String("method", c.Request.Method),
+		slog.String("path", c.Request.URL.Path),
+		slog.Int("status", status),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("ip", c.ClientIP()),
+	)
+}
@@ -0,0 +1,46 @@
+// Package ginkit plugs the shared grademyprofShared packages (problem,
+// logging, tracing, ratelimit, audit) into Gin.
+package ginkit
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// Abort records p as the request's error and stops the handler chain.
+// Problems renders it once the chain unwinds.
+func Abort(c *gin.Context, p *problem.Problem) {
+	_ = c.Error(p)
+	c.Abort()
+}
+
+// Problems renders the last error recorded with c.Error as a Problem,
+// unless a handler already wrote a response. Errors that aren't already a
+// *Problem are logged and hidden behind a generic message.
+func Problems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		var p *problem.Problem
+		if !errors.As(err, &p) {
+			slog.ErrorContext(c.Request.Context(), "unhandled error", "method", c.Request.Method, "path", c.Request.URL.Path, "err", err)
+			p = problem.Internal("Internal server error")
+		}
+
+		// Copy so a shared *Problem value is never mutated per request
+		rendered := *p
+		rendered.Instance = c.Request.URL.Path
+		rendered.RequestID = c.GetString("request_id")
+
+		c.Header("Content-Type", problem.ContentType)
+		c.JSON(rendered.Status, rendered)
+	}
+}
@@ -0,0 +1,36 @@
+package ginkit
+
+import (
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit counts each request against l, sets the RateLimit-* headers
+// (and Retry-After on rejections) and hands rejected ones to reject, which
+// must abort. Requests are keyed by the user RequireAuthHeader verified,
+// so mount it after RequireAuthHeader on authenticated routes; anywhere
+// else it keys by client IP.
+func RateLimit(l *ratelimit.Limiter, reject func(c *gin.Context, res ratelimit.Result)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res := l.Allow(c.Request.Context(), RateLimitIdentity(c))
+		if res.Tighter(c.Writer.Header().Get(ratelimit.HeaderRemaining)) || !res.Allowed {
+			for name, value := range res.Headers() {
+				c.Header(name, value)
+			}
+		}
+		if !res.Allowed {
+			reject(c, res)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RateLimitIdentity is the verified user if there is one, the client IP
+// otherwise.
+func RateLimitIdentity(c *gin.Context) string {
+	if email := c.GetString("user_email"); email != "" {
+		return ratelimit.UserKey(email)
+	}
+	return ratelimit.IPKey(c.ClientIP())
+}
@@ -0,0 +1,63 @@
+package initializer
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/config"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/middleware"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/revocation"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/logging"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/ratelimit"
+)
+
+// Init sets up logging, the JWT and metrics settings, rate limit, revocation and audit
+// storage and Firebase from cfg.
+func Init(cfg *config.Config) {
+	if err := logging.Setup("grademyprofAuth", cfg.LogLevel); err != nil {
+		log.Fatal(err)
+	}
+
+	middleware.JWTSecret = []byte(cfg.JWTSecret)
+	middleware.TokenLifetime = cfg.TokenLifetime
+	middleware.ModeratorEmails = cfg.ModeratorEmails
+	middleware.AdminEmails = cfg.AdminEmails
+	middleware.MetricsToken = []byte(cfg.MetricsToken)
+
+	audit.Service = "grademyprofAuth"
+	audit.PseudonymKey = []byte(cfg.AuditKey)
+	if cfg.SupabaseURL != "" {
+		middleware.AuditLog = audit.NewSupabase(cfg.SupabaseURL, cfg.SupabaseServiceRoleKey)
+	} else {
+		slog.Warn("SUPABASE_URL is not set, audit entries only go to the log")
+	}
+
+	if cfg.RateLimitStore == "redis" {
+		store, err := ratelimit.NewRedis(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to set up rate limiting: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := store.Ping(ctx); err != nil {
+			// Limiters let requests through while Redis is down
+			slog.Warn("redis unreachable, rate limits are not enforced until it is back", "err", err)
+		}
+		cancel()
+		middleware.RateLimitStore = store
+	}
+
+	if cfg.RevocationStore == "redis" {
+		store, err := revocation.NewRedis(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to set up token revocation: %v", err)
+		}
+		middleware.Revocations = store
+	}
+
+	if err := middleware.InitFirebase(cfg.FirebaseServiceAccountKey, cfg.FirebaseServiceAccountPath); err != nil {
+		log.Fatalf("Failed to initialize Firebase: %v", err)
+	}
+}
@@ -1,39 +1,169 @@
 package main
 
 import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/config"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/controller"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/ginkit"
 	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/initializer"
 	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/middleware"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/envconfig"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/tracing"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+//go:embed openapi.json
+var openAPISpec []byte
 
-func init(){
-	initializer.LoadEnvVars()
-}
+// docsPage renders openapi.json with Redoc.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>GradeMyProf Auth</title>
+  <meta charset="utf-8" />
+  <meta name="viewport" content="width=device-width, initial-scale=1" />
+</head>
+<body>
+  <redoc spec-url="/openapi.json"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>`
 
 func main(){
-	r:=gin.Default()
+	configPath := flag.String("config", "", "read settings from this .env-style file (environment variables still win)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration, secrets redacted, and exit")
+	flag.Parse()
+
+	// Load environment variables
+	envErr := godotenv.Load()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *printConfig {
+		if err := envconfig.Print(os.Stdout, cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	initializer.Init(cfg)
+	if envErr != nil {
+		slog.Info("no .env file found")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "grademyprofAuth")
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	r, err := newRouter(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	slog.Info("server starting", "port", cfg.Port)
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	if err := listenAndServe(srv, cfg.ShutdownTimeout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newRouter sets up every middleware and route. initializer.Init must have
+// run. openapi_test.go checks the routes against openapi.json.
+func newRouter(cfg *config.Config) (*gin.Engine, error) {
+	r:=gin.New()
+	// c.ClientIP() keys login limits and the audit log: only believe
+	// X-Forwarded-For from our own proxies
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	r.Use(gin.Recovery())
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://192.168.2.3", "https://kaifn8n.online"}, // Local dev + Campus + Production
+		AllowOrigins:     cfg.CORSOrigins,
         AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-        AllowHeaders:     []string{"Content-Type", "Authorization"},
+        AllowHeaders:     []string{"Content-Type", "Authorization", "X-Request-ID"},
+        ExposeHeaders:    []string{"X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
         AllowCredentials: true,
 		
 	}))
+	r.Use(middleware.RequestID, ginkit.Tracing, ginkit.Logging, middleware.Metrics, ginkit.Problems())
 
-	r.POST("/login",middleware.RateLimiter(5, time.Minute),middleware.VerifyFirebaseToken, middleware.GenerateJWT)
+	r.GET("/healthz", controller.Healthz)
+	r.GET("/readyz", controller.Readyz)
+	r.GET("/metrics", middleware.RequireMetricsToken, gin.WrapH(promhttp.Handler()))
+	r.NoRoute(func(c *gin.Context) {
+		ginkit.Abort(c, problem.NotFound("Route not found"))
+	})
+
+	r.POST("/login",middleware.RateLimiter("login", cfg.LoginRateLimit, cfg.LoginRateWindow),middleware.VerifyFirebaseToken, middleware.GenerateJWT)
+
+	r.POST("/revoke", middleware.RequireAuthHeader, middleware.RevokeTokens)
 
 	r.GET("/verify-token", middleware.RequireAuthHeader, func(c *gin.Context) {
         email, _ := c.Get("user_email")
-        c.JSON(http.StatusOK, gin.H{
+        response := gin.H{
             "valid": true,
             "email": email,
-        })
+            "role":  c.GetString("user_role"),
+        }
+        if created, ok := c.Get("account_created"); ok {
+            response["account_created"] = created
+        }
+        c.JSON(http.StatusOK, response)
     })
 
-	r.Run()
-}
\ No newline at end of file
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", openAPISpec)
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	})
+
+	return r, nil
+}
+
+// listenAndServe runs srv until SIGINT or SIGTERM, then stops accepting
+// connections and waits up to timeout for in-flight requests.
+func listenAndServe(srv *http.Server, timeout time.Duration) error {
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- srv.ListenAndServe()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-listenErr:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("shutting down", "shutdown_timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("requests still in flight after shutdown timeout", "err", err)
+	}
+	return nil
+}
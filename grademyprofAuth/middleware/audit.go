@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditLog stores audit entries. initializer.Init replaces it with the
+// audit_log table when SUPABASE_URL is set.
+var AuditLog audit.Store = audit.Log{}
+
+// recordAudit stores entry, counting it when it only made it to the log.
+func recordAudit(c *gin.Context, entry audit.Entry) {
+	if err := audit.Record(c.Request.Context(), AuditLog, entry); err != nil {
+		auditFailures.Inc()
+	}
+}
@@ -1,11 +1,14 @@
 package middleware
 
 import (
-	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/ginkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -14,44 +17,86 @@ type User struct{
 	Email string `json:"email" binding:"required"`
 }
 
+// JWTSecret signs and verifies our tokens, and TokenLifetime is how long a
+// token stays valid. ModeratorEmails get the moderator role and AdminEmails
+// the admin role. main sets them all from the configuration.
+var (
+	JWTSecret       []byte
+	TokenLifetime   time.Duration
+	ModeratorEmails []string
+	AdminEmails     []string
+)
+
+// Roles carried in the token's "role" claim. Tokens issued before roles
+// existed have none and count as students. Admins can do everything
+// moderators can.
+const (
+	RoleStudent   = "student"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+func roleFor(email string) string {
+	for _, admin := range AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return RoleAdmin
+		}
+	}
+	for _, moderator := range ModeratorEmails {
+		if strings.EqualFold(moderator, email) {
+			return RoleModerator
+		}
+	}
+	return RoleStudent
+}
 
 
 func GenerateJWT(c *gin.Context){
 	// Get the verified email from context (set by VerifyFirebaseToken middleware)
 	verifiedEmail, exists := c.Get("verified_email")
 	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error":"Email verification required"})
+		ginkit.Abort(c, problem.BadRequest(problem.CodeBadRequest, "Email verification required"))
 		return
 	}
 
 	email, ok := verifiedEmail.(string)
 	if !ok || email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error":"Invalid email"})
+		ginkit.Abort(c, problem.BadRequest(problem.CodeBadRequest, "Invalid email"))
 		return
 	}
 
-	fmt.Println("Generating JWT Token")
-
-	jwtSecret := os.Getenv("JWT_SECRET")
+	slog.DebugContext(c.Request.Context(), "generating JWT", "email", email)
 
-	if jwtSecret == ""{
-		c.JSON(http.StatusInternalServerError, gin.H{"error":"JWT secret not configured"})
+	if len(JWTSecret) == 0{
+		ginkit.Abort(c, problem.Internal("JWT secret not configured"))
 		return
 	}
 
-	token:=jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	role := roleFor(email)
+	claims := jwt.MapClaims{
 		"sub": email,
+		"role": role,
 		"iat": time.Now().Unix(),
-		"exp": time.Now().Add(time.Hour*24*30).Unix(),
+		"exp": time.Now().Add(TokenLifetime).Unix(),
+	}
+	// Lets grademyprofAPI spot reviews from freshly created accounts
+	if created := c.GetTime("account_created"); !created.IsZero() {
+		claims["account_created"] = created.Unix()
+	}
 
-	})
+	token:=jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := token.SignedString(JWTSecret)
 	if err!=nil{
-		c.JSON(http.StatusInternalServerError, gin.H{"error":"Failed to generate token"})
+		ginkit.Abort(c, problem.Internal("Failed to generate token"))
 		return
 	}
 
+	entry := ginkit.AuditEntry(c, audit.ActionLogin, "account", audit.Pseudonym(email))
+	entry.Actor, entry.ActorRole = audit.Pseudonym(email), role
+	entry.After = audit.Snapshot(gin.H{"role": role, "expires_at": claims["exp"]})
+	recordAudit(c, entry)
+
 	c.JSON(http.StatusOK, gin.H{
 		"token" : tokenString,
 		"email" : email,
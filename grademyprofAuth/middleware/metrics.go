@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/ginkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "grademyprof_auth"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	rateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by each rate limiter.",
+	}, []string{"limiter"})
+
+	tokenRevocations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_revocations_total",
+		Help:      "Accounts whose tokens were all revoked.",
+	})
+
+	auditFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "audit_failures_total",
+		Help:      "Audit entries that could not be stored and went to the log instead.",
+	})
+)
+
+// MetricsToken is the bearer token RequireMetricsToken accepts
+// (METRICS_TOKEN). Empty, it accepts none.
+var MetricsToken []byte
+
+// RequireMetricsToken lets through Prometheus scrapers sending
+// MetricsToken only: the route and error counts are no business of the
+// public.
+func RequireMetricsToken(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		ginkit.Abort(c, problem.Unauthorized(problem.CodeMissingToken, "Missing metrics token"))
+		return
+	}
+	if len(MetricsToken) == 0 || subtle.ConstantTimeCompare([]byte(token), MetricsToken) != 1 {
+		ginkit.Abort(c, problem.Unauthorized(problem.CodeInvalidToken, "Invalid metrics token"))
+		return
+	}
+	c.Next()
+}
+
+// Metrics records request counts and latency, labelled by route template.
+// Requests that match no route are grouped under "unmatched".
+func Metrics(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+
+	httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+	httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+}
@@ -1,81 +1,26 @@
 package middleware
 
 import (
-	"net/http"
-	"sync"
 	"time"
 
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/ginkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/ratelimit"
 	"github.com/gin-gonic/gin"
 )
 
-type visitor struct{
-	lastSeen time.Time
-	count int	
+// RateLimitStore holds every limiter's counters. initializer.Init replaces it with a
+// Redis store when RATE_LIMIT_STORE=redis, before the routes are set up.
+var RateLimitStore ratelimit.Store = ratelimit.NewMemory()
+
+// RateLimiter allows maxRequests per client within a sliding window. Each
+// name gets its own buckets and labels rejections in the
+// rate_limit_rejections_total metric. Clients are keyed by verified user
+// when mounted after RequireAuthHeader, by IP otherwise.
+func RateLimiter(name string, maxRequests int, window time.Duration) gin.HandlerFunc {
+	l := &ratelimit.Limiter{Name: name, Limit: maxRequests, Window: window, Store: RateLimitStore}
+	return ginkit.RateLimit(l, func(c *gin.Context, res ratelimit.Result) {
+		rateLimitRejections.WithLabelValues(name).Inc()
+		ginkit.Abort(c, problem.RateLimited("Too many requests, try again later"))
+	})
 }
-
-var(
-	visitors = make(map[string]*visitor)
-	mu sync.Mutex
-)
-
-func init(){
-	go cleanupVisitors()
-}
-
-func cleanupVisitors(){
-	for{
-		time.Sleep(3* time.Minute)
-		mu.Lock()
-		for ip, v:= range visitors{
-			if time.Since(v.lastSeen)> 3*time.Minute{
-				delete(visitors, ip)
-			}
-		}
-		mu.Unlock()
-	}
-}
-
-func RateLimiter(maxRequests int, window time.Duration) gin.HandlerFunc{
-
-	return func(c *gin.Context){
-		ip := c.ClientIP()
-		mu.Lock()
-		v, exists := visitors[ip]
-		if !exists{
-			visitors[ip] =&visitor{
-				lastSeen : time.Now(),
-				count: 1,
-			}
-			mu.Unlock()
-		c.Next()
-		return
-		}
-
-		if time.Since(v.lastSeen) >window{
-			v.lastSeen = time.Now()
-			v.count =1
-			mu.Unlock()
-			c.Next()
-			return
-		}
-
-		v.count++
-		v.lastSeen = time.Now()
-
-		if v.count > maxRequests{
-			mu.Unlock()
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":"Rate limit exceeded, try again later",
-			})
-			c.Abort()
-			return
-		}
-
-		mu.Unlock()
-		c.Next()
-
-		
-	}
-
-}
-
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestID reuses the caller's X-Request-ID or generates one, and echoes
+// it back on the response.
+func RequestID(c *gin.Context) {
+	id := c.GetHeader("X-Request-ID")
+	if id == "" || len(id) > 128 {
+		id = uuid.NewString()
+	}
+
+	c.Set("request_id", id)
+	c.Header("X-Request-ID", id)
+	c.Next()
+}
@@ -2,10 +2,10 @@ package middleware
 
 import (
 	"fmt"
-	"net/http"
-	"os"
 	"strings"
 
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/ginkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -14,20 +14,14 @@ import (
 func RequireAuthHeader(c *gin.Context){
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == ""{
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Missing auth token",
-		})
-		c.Abort()
+		ginkit.Abort(c, problem.Unauthorized(problem.CodeMissingToken, "Missing authorization token"))
 		return
 	}
 
 
 	tokenParts := strings.Split(authHeader, " ")
 	if len(tokenParts) != 2 || tokenParts[0] != "Bearer"{
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid authorization format",
-		})
-		c.Abort()
+		ginkit.Abort(c, problem.Unauthorized(problem.CodeInvalidToken, "Invalid authorization format"))
 		return
 	}
 
@@ -40,27 +34,34 @@ func RequireAuthHeader(c *gin.Context){
 
 		}
 
-		jwtSecret := os.Getenv("JWT_SECRET")
-		if jwtSecret ==""{
+		if len(JWTSecret) == 0{
 			return nil, fmt.Errorf("JWT_SECRET not configured")
 		}
 
-		return []byte(jwtSecret), nil
+		return JWTSecret, nil
 	})
 
 	if err != nil || !token.Valid{
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error":"Invalid or expired token",
-		})
-
-		c.Abort()
+		ginkit.Abort(c, problem.Unauthorized(problem.CodeInvalidToken, "Invalid or expired token"))
 		return
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok{
 		if email, ok := claims["sub"].(string); ok{
+			if isRevoked(c, email, claims) {
+				ginkit.Abort(c, problem.Unauthorized(problem.CodeInvalidToken, "Token has been revoked"))
+				return
+			}
 			c.Set("user_email", email)
 		}
+		role, _ := claims["role"].(string)
+		if role == "" {
+			role = RoleStudent
+		}
+		c.Set("user_role", role)
+		if created, ok := claims["account_created"].(float64); ok {
+			c.Set("account_created", int64(created))
+		}
 	}
 
 	c.Next()
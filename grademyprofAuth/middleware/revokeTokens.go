@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/ginkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/revocation"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/audit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Revocations records revoked tokens. initializer.Init replaces it with a
+// Redis store when REVOCATION_STORE=redis, before the routes are set up.
+var Revocations revocation.Store = revocation.NewMemory()
+
+// isRevoked reports whether the token with claims was revoked. Tokens are
+// let through while the store is unreachable: an outage shouldn't sign
+// everyone out.
+func isRevoked(c *gin.Context, subject string, claims jwt.MapClaims) bool {
+	revokedAt, err := Revocations.RevokedAt(c.Request.Context(), subject)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "revocation check failed, accepting token", "err", err)
+		return false
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		// Every token we issue has iat; without one it can't predate anything
+		return !revokedAt.IsZero()
+	}
+	return revocation.IsRevoked(issuedAt.Time, revokedAt)
+}
+
+// RevokeTokens signs the caller out everywhere: every token issued to them
+// so far stops working. grademyprofAPI calls it when an account is
+// deleted. Mount it after RequireAuthHeader.
+func RevokeTokens(c *gin.Context) {
+	email := c.GetString("user_email")
+	if email == "" {
+		ginkit.Abort(c, problem.Unauthorized(problem.CodeInvalidToken, "Invalid or expired token"))
+		return
+	}
+
+	now := time.Now()
+	if err := Revocations.Revoke(c.Request.Context(), email, now, TokenLifetime); err != nil {
+		slog.ErrorContext(c.Request.Context(), "token revocation failed", "email", email, "err", err)
+		ginkit.Abort(c, problem.New(http.StatusServiceUnavailable, problem.CodeUpstream, "Failed to revoke tokens"))
+		return
+	}
+	tokenRevocations.Inc()
+	slog.InfoContext(c.Request.Context(), "tokens revoked", "email", email)
+
+	entry := ginkit.AuditEntry(c, audit.ActionRevokeTokens, "account", audit.Pseudonym(email))
+	entry.After = audit.Snapshot(gin.H{"revoked_at": now.Unix()})
+	recordAudit(c, entry)
+
+	c.JSON(http.StatusOK, gin.H{"revoked_at": now.Unix()})
+}
@@ -2,24 +2,34 @@ package middleware
 
 import (
 	"context"
-	"log"
-	"os"
+	"errors"
+	"log/slog"
+	"time"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/ginkit"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/problem"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/option"
 )
 
+var tracer = otel.Tracer("github.com/Koifish2004/ProfessorWeb/grademyprofAuth/middleware")
+
 
 var firebaseApp *firebase.App
 var firebaseAuth *auth.Client
 
-func InitFirebase(serviceAccountPath string) error{
+// InitFirebase sets up the admin SDK from the service account key JSON, or
+// from the file at serviceAccountPath when credJSON is empty.
+func InitFirebase(credJSON, serviceAccountPath string) error{
 	var opt option.ClientOption
 	
-	// Check if Firebase credentials are in environment variable (for Railway)
-	if credJSON := os.Getenv("FIREBASE_SERVICE_ACCOUNT_KEY"); credJSON != "" {
+	// Firebase credentials passed as an environment variable (for Railway)
+	if credJSON != "" {
 		opt = option.WithCredentialsJSON([]byte(credJSON))
 	} else {
 		// Fall back to file path (for local development)
@@ -37,7 +47,15 @@ func InitFirebase(serviceAccountPath string) error{
 	}
 	firebaseApp = app
 	firebaseAuth = authClient
-	log.Println("FAdminSDK init success")
+	slog.Info("firebase admin SDK initialized")
+	return nil
+}
+
+// FirebaseReady reports whether InitFirebase has set up the auth client.
+func FirebaseReady() error {
+	if firebaseAuth == nil {
+		return errors.New("firebase auth client not initialized")
+	}
 	return nil
 }
 
@@ -51,43 +69,53 @@ func VerifyFirebaseToken(c *gin.Context){
 	if err := c.ShouldBindJSON(&requestBody);
 	err!= nil{
 
-		c.JSON(400, gin.H{
-			"error":"Ivalid Body req",
-		})
-		c.Abort()
+		ginkit.Abort(c, problem.BadRequest(problem.CodeInvalidBody, "Invalid request body"))
 		return
 	}
 
-	token, err :=firebaseAuth.VerifyIDToken(context.Background(), requestBody.FirebaseToken)
+	ctx, span := tracer.Start(c.Request.Context(), "firebase.verify_id_token", trace.WithSpanKind(trace.SpanKindClient))
+	token, err :=firebaseAuth.VerifyIDToken(ctx, requestBody.FirebaseToken)
+	if err != nil {
+		span.SetStatus(codes.Error, "verification failed")
+	}
+	span.End()
 
 	if err != nil{
-		log.Printf("Firebase token verification failed: %v", err)
-        c.JSON(401, gin.H{"error": "Invalid Firebase token"})
-        c.Abort()
+		slog.WarnContext(c.Request.Context(), "firebase token verification failed", "err", err)
+        ginkit.Abort(c, problem.Unauthorized(problem.CodeInvalidFirebaseToken, "Invalid Firebase token"))
         return
 	}
 
 	email, ok := token.Claims["email"].(string)
     if !ok || email == "" {
-        c.JSON(401, gin.H{"error": "Email not found in token"})
-        c.Abort()
+        ginkit.Abort(c, problem.Unauthorized(problem.CodeInvalidFirebaseToken, "Email not found in token"))
         return
     }
 
 	if email != requestBody.Email {
-        c.JSON(401, gin.H{"error": "Email mismatch"})
-        c.Abort()
+        ginkit.Abort(c, problem.Unauthorized(problem.CodeEmailMismatch, "Email mismatch"))
         return
     }
 
 	emailVerified, ok := token.Claims["email_verified"].(bool)
     if !ok || !emailVerified {
-        c.JSON(401, gin.H{"error": "Email not verified"})
-        c.Abort()
+        ginkit.Abort(c, problem.Unauthorized(problem.CodeEmailNotVerified, "Email not verified"))
         return
     }
 
-	log.Printf("firebase token verified for: %s", email)
+	slog.InfoContext(c.Request.Context(), "firebase token verified", "email", email)
+
+	// The token doesn't say when the account was created, the user record
+	// does. Logins still succeed without it.
+	ctx, span = tracer.Start(c.Request.Context(), "firebase.get_user", trace.WithSpanKind(trace.SpanKindClient))
+	user, err := firebaseAuth.GetUser(ctx, token.UID)
+	if err != nil {
+		span.SetStatus(codes.Error, "lookup failed")
+		slog.WarnContext(c.Request.Context(), "firebase user lookup failed", "err", err)
+	} else if user.UserMetadata != nil {
+		c.Set("account_created", time.UnixMilli(user.UserMetadata.CreationTimestamp))
+	}
+	span.End()
 
 	  c.Set("verified_email", email)
     c.Next()
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/config"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofAuth/middleware"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// contractCase is one request the contract test sends, in order. token
+// sends a valid token for student@pilani.bits-pilani.ac.in, metrics the
+// METRICS_TOKEN.
+type contractCase struct {
+	method  string
+	path    string
+	token   bool
+	metrics bool
+	body    string
+	status  int
+}
+
+const metricsToken = "metrics"
+
+// Firebase isn't set up in tests, so /readyz is not ready and /login is
+// only called with requests turned away before the Firebase check.
+var contractCases = []contractCase{
+	{method: "GET", path: "/healthz", status: 200},
+	{method: "GET", path: "/readyz", status: 503},
+	{method: "GET", path: "/metrics", status: 401},
+	{method: "GET", path: "/metrics", metrics: true, status: 200},
+	{method: "GET", path: "/openapi.json", status: 200},
+	{method: "GET", path: "/docs", status: 200},
+
+	{method: "POST", path: "/login", body: `{`, status: 400},
+	{method: "POST", path: "/login", body: `{`, status: 429},
+
+	{method: "GET", path: "/verify-token", status: 401},
+	{method: "GET", path: "/verify-token", token: true, status: 200},
+	{method: "POST", path: "/revoke", token: true, status: 200},
+}
+
+// TestOpenAPIContract sends contractCases through the router and checks
+// every response against openapi.json, then that every documented
+// operation was called and every route is documented.
+func TestOpenAPIContract(t *testing.T) {
+	r := newTestRouter(t)
+	doc := loadOpenAPI(t)
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := studentToken(t)
+
+	called := map[string]bool{}
+	for _, tc := range contractCases {
+		name := tc.method + " " + tc.path
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if tc.token {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if tc.metrics {
+			req.Header.Set("Authorization", "Bearer "+metricsToken)
+		}
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		resp := rec.Result()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: got status %d, want %d: %s", name, resp.StatusCode, tc.status, body)
+			continue
+		}
+
+		route, pathParams, err := router.FindRoute(req)
+		if err != nil {
+			t.Errorf("%s: not in openapi.json: %v", name, err)
+			continue
+		}
+		called[route.Method+" "+route.Path] = true
+
+		if err := validateResponse(req, route, pathParams, resp, body); err != nil {
+			t.Errorf("%s: response doesn't match openapi.json: %v", name, err)
+		}
+	}
+
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			if !called[method+" "+path] {
+				t.Errorf("%s %s is documented but no contract case calls it", method, path)
+			}
+		}
+	}
+
+	for _, route := range r.Routes() {
+		item := doc.Paths.Find(ginParam.ReplaceAllString(route.Path, "{$1}"))
+		if item == nil || item.GetOperation(route.Method) == nil {
+			t.Errorf("%s %s is not documented in openapi.json", route.Method, route.Path)
+		}
+	}
+}
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+func loadOpenAPI(t *testing.T) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromData(openAPISpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("openapi.json is invalid: %v", err)
+	}
+	// Match on paths only, wherever the service happens to be served
+	doc.Servers = nil
+	return doc
+}
+
+func validateResponse(req *http.Request, route *routers.Route, pathParams map[string]string, resp *http.Response, body []byte) error {
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: pathParams,
+			Route:      route,
+		},
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+		},
+	}
+	input.SetBodyBytes(body)
+	return openapi3filter.ValidateResponse(context.Background(), input)
+}
+
+func init() {
+	// The docs page is the only HTML response
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.FileBodyDecoder)
+}
+
+// newTestRouter sets up what initializer.Init would, short of Firebase and
+// the Supabase audit log, with one login allowed per window.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("JWT_SECRET", "test")
+	t.Setenv("FIREBASE_SERVICE_ACCOUNT_PATH", "serviceAccountKey.json")
+	t.Setenv("LOGIN_RATE_LIMIT_MAX", "1")
+	t.Setenv("METRICS_TOKEN", metricsToken)
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	middleware.JWTSecret = []byte(cfg.JWTSecret)
+	middleware.TokenLifetime = cfg.TokenLifetime
+	middleware.MetricsToken = []byte(cfg.MetricsToken)
+	r, err := newRouter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// studentToken signs a token the way GenerateJWT does.
+func studentToken(t *testing.T) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":             "student@pilani.bits-pilani.ac.in",
+		"role":            middleware.RoleStudent,
+		"iat":             time.Now().Unix(),
+		"exp":             time.Now().Add(middleware.TokenLifetime).Unix(),
+		"account_created": time.Now().AddDate(-1, 0, 0).Unix(),
+	}).SignedString(middleware.JWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
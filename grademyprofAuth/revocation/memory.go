@@ -0,0 +1,48 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a Store for a single replica. Revocations are lost on restart.
+type Memory struct {
+	mu      sync.Mutex
+	revoked map[string]record
+}
+
+type record struct {
+	at      time.Time
+	expires time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{revoked: make(map[string]record)}
+}
+
+func (m *Memory) Revoke(_ context.Context, subject string, at time.Time, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Drop what has expired, revocations are rare enough to sweep on write
+	now := time.Now()
+	for k, r := range m.revoked {
+		if now.After(r.expires) {
+			delete(m.revoked, k)
+		}
+	}
+	m.revoked[key(subject)] = record{at: at, expires: now.Add(ttl)}
+	return nil
+}
+
+func (m *Memory) RevokedAt(_ context.Context, subject string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.revoked[key(subject)]
+	if !ok || time.Now().After(r.expires) {
+		return time.Time{}, nil
+	}
+	return r.at, nil
+}
@@ -0,0 +1,53 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Store every replica shares, on any server that speaks the
+// Redis protocol.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis connects to url, e.g. redis://:password@host:6379/0.
+func NewRedis(url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+func (r *Redis) Revoke(ctx context.Context, subject string, at time.Time, ttl time.Duration) error {
+	return r.client.Set(ctx, "revoked:"+key(subject), at.Unix(), ttl).Err()
+}
+
+func (r *Redis) RevokedAt(ctx context.Context, subject string) (time.Time, error) {
+	value, err := r.client.Get(ctx, "revoked:"+key(subject)).Result()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// Ping checks the server is reachable.
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
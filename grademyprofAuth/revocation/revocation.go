@@ -0,0 +1,35 @@
+// Package revocation remembers revoked tokens. Tokens are stateless JWTs,
+// so they're revoked per subject: every token issued to the subject up to
+// the revocation time stops working, and later logins are unaffected.
+package revocation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Store records revocations.
+type Store interface {
+	// Revoke invalidates subject's tokens issued up to at. The record may be
+	// forgotten after ttl, once every such token has expired anyway.
+	Revoke(ctx context.Context, subject string, at time.Time, ttl time.Duration) error
+
+	// RevokedAt returns when subject's tokens were last revoked, the zero
+	// time if they never were.
+	RevokedAt(ctx context.Context, subject string) (time.Time, error)
+}
+
+// IsRevoked reports whether a token issued at issuedAt was revoked by a
+// revocation at revokedAt. Token times have second precision, so a token
+// issued in the same second as the revocation counts as revoked.
+func IsRevoked(issuedAt, revokedAt time.Time) bool {
+	return !revokedAt.IsZero() && issuedAt.Unix() <= revokedAt.Unix()
+}
+
+// key keeps emails out of the store's keys.
+func key(subject string) string {
+	sum := sha256.Sum256([]byte(subject))
+	return hex.EncodeToString(sum[:])
+}
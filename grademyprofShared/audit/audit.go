@@ -0,0 +1,128 @@
+// Package audit records privileged and destructive actions, by either
+// service, in the append-only audit_log table
+// (migrations/013_audit_log.sql). Admins query it through grademyprofAPI's
+// /api/admin/audit.
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Action names what was done. Admins filter on these, so never rename or
+// reuse one; add a new action instead.
+type Action string
+
+const (
+	// grademyprofAuth
+	ActionLogin        Action = "auth.login"
+	ActionRevokeTokens Action = "auth.revoke_tokens"
+
+	// grademyprofAPI
+	ActionReviewUpdate  Action = "review.update"
+	ActionReviewDelete  Action = "review.delete"
+	ActionReviewRestore Action = "review.restore"
+	ActionReviewPurge   Action = "review.purge"
+	ActionReviewApprove Action = "review.approve"
+	ActionReviewReject  Action = "review.reject"
+	ActionAccountDelete Action = "account.delete"
+	ActionWebhookCreate Action = "webhook.create"
+	ActionWebhookUpdate Action = "webhook.update"
+	ActionWebhookDelete Action = "webhook.delete"
+	ActionWebhookReplay Action = "webhook.replay"
+)
+
+// SystemActor is the actor of background jobs.
+const SystemActor = "system"
+
+// Entry is one audited action. Before and After are JSON snapshots of the
+// target, either may be missing. The log outlives accounts, so it holds no
+// raw identifiers: Actor, IP and account target IDs are Pseudonyms.
+type Entry struct {
+	ID         int64           `json:"id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Service    string          `json:"service"`
+	Actor      string          `json:"actor"`
+	ActorRole  string          `json:"actor_role,omitempty"`
+	Action     Action          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+}
+
+// Store appends entries to the audit log.
+type Store interface {
+	Append(ctx context.Context, entry Entry) error
+}
+
+// Service names the service writing entries, set at startup.
+var Service string
+
+// PseudonymKey keys Pseudonym, set at startup (AUDIT_KEY). Both services
+// use the same key so a user's entries from either share one pseudonym.
+var PseudonymKey []byte
+
+// Pseudonym stands in for an email or IP in the log: a keyed hash, so
+// entries by the same user still line up, but nobody without the key can
+// tell whose they are. Emails are compared case-insensitively. Empty stays
+// empty.
+func Pseudonym(identifier string) string {
+	if identifier == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, PseudonymKey)
+	mac.Write([]byte(strings.ToLower(identifier)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Snapshot encodes v for Entry.Before or Entry.After; nil stays missing.
+func Snapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// Record appends entry to store. The action has already happened by the
+// time it's audited, so a failed write doesn't undo it: the entry goes to
+// the log instead and the error is returned for counting.
+func Record(ctx context.Context, store Store, entry Entry) error {
+	entry.Service = Service
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+
+	// A client hanging up mustn't lose the entry
+	err := store.Append(context.WithoutCancel(ctx), entry)
+	if err != nil {
+		slog.ErrorContext(ctx, "audit entry not stored", "err", err,
+			"action", entry.Action, "actor", entry.Actor, "target_type", entry.TargetType, "target_id", entry.TargetID,
+			"before", string(entry.Before), "after", string(entry.After))
+	}
+	return err
+}
+
+// Log is a Store that only writes entries to the log, for running without
+// the audit table.
+type Log struct{}
+
+func (Log) Append(ctx context.Context, entry Entry) error {
+	slog.InfoContext(ctx, "audit",
+		"action", entry.Action, "actor", entry.Actor, "actor_role", entry.ActorRole,
+		"target_type", entry.TargetType, "target_id", entry.TargetID,
+		"before", string(entry.Before), "after", string(entry.After))
+	return nil
+}
@@ -0,0 +1,37 @@
+package audit
+
+import "testing"
+
+func TestPseudonym(t *testing.T) {
+	PseudonymKey = []byte("test")
+	t.Cleanup(func() { PseudonymKey = nil })
+	student := Pseudonym("student@pilani.bits-pilani.ac.in")
+
+	tests := []struct {
+		name       string
+		identifier string
+		key        string
+		same       bool
+	}{
+		{"same user", "student@pilani.bits-pilani.ac.in", "test", true},
+		{"emails ignore case", "Student@Pilani.BITS-Pilani.ac.in", "test", true},
+		{"another user", "other@pilani.bits-pilani.ac.in", "test", false},
+		{"another key", "student@pilani.bits-pilani.ac.in", "other", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			PseudonymKey = []byte(tt.key)
+			got := Pseudonym(tt.identifier)
+			if (got == student) != tt.same {
+				t.Errorf("Pseudonym(%q) = %q, same as the student's: %t, want %t", tt.identifier, got, got == student, tt.same)
+			}
+			if len(got) != 32 {
+				t.Errorf("Pseudonym(%q) = %q, want 32 hex characters", tt.identifier, got)
+			}
+		})
+	}
+
+	if got := Pseudonym(""); got != "" {
+		t.Errorf("Pseudonym(\"\") = %q, want empty", got)
+	}
+}
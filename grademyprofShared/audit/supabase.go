@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/logging"
+	"github.com/Koifish2004/ProfessorWeb/grademyprofShared/tracing"
+)
+
+// Supabase appends entries to the audit_log table grademyprofAPI reads
+// them from, for services without a Supabase client of their own
+// (grademyprofAuth).
+type Supabase struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewSupabase writes to the project at url (SUPABASE_URL) with apiKey, the
+// service role key (SUPABASE_SERVICE_ROLE_KEY): only the service role may
+// append to audit_log.
+func NewSupabase(url, apiKey string) *Supabase {
+	return &Supabase{url: url, apiKey: apiKey, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (s *Supabase) Append(ctx context.Context, entry Entry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/rest/v1/audit_log", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", s.apiKey)
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "return=minimal")
+	req.Header.Set(logging.RequestIDHeader, logging.RequestID(ctx))
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("supabase returned status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Package envconfig fills a service's typed configuration from struct
+// tags. Each service declares its Config struct and its validation in its
+// own config package.
+package envconfig
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors lists every invalid setting found at startup, so one failed deploy
+// shows all of them instead of the first.
+type Errors []string
+
+func (e Errors) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
+
+// Addf records an invalid setting.
+func (e *Errors) Addf(format string, args ...any) {
+	*e = append(*e, fmt.Sprintf(format, args...))
+}
+
+// Merge appends the validation errors in more, skipping settings that
+// already failed to parse (their zero value would fail validation too).
+func (e Errors) Merge(more Errors) Errors {
+	failed := make(map[string]bool, len(e))
+	for _, err := range e {
+		name, _, _ := strings.Cut(err, ":")
+		failed[name] = true
+	}
+	for _, err := range more {
+		if name, _, _ := strings.Cut(err, ":"); !failed[name] {
+			e = append(e, err)
+		}
+	}
+	return e
+}
+
+// Parse fills cfg, a pointer to a struct, from its field tags:
+//
+//	Port string `env:"PORT" default:"4000"`
+//	Key  string `env:"API_KEY" secret:"true"`
+//
+// Each value comes from the environment, then from file (the --config file),
+// then from the default; empty values count as unset. Supported field types
+// are string, bool, int, float64, time.Duration and []string
+// (comma-separated).
+func Parse(cfg any, file map[string]string) Errors {
+	var errs Errors
+	walk(reflect.ValueOf(cfg).Elem(), func(value reflect.Value, field reflect.StructField) {
+		name := field.Tag.Get("env")
+		raw := os.Getenv(name)
+		if raw == "" {
+			raw = file[name]
+		}
+		if raw == "" {
+			raw = field.Tag.Get("default")
+		}
+		if err := set(value, raw); err != nil {
+			errs.Addf("%s: %v", name, err)
+		}
+	})
+	return errs
+}
+
+func walk(v reflect.Value, fn func(reflect.Value, reflect.StructField)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("env") != "" {
+			fn(v.Field(i), field)
+		}
+	}
+}
+
+func set(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		if raw == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("must be a duration like 30s or 2m, got %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		if raw == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Float64:
+		if raw == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config field type %s", v.Type())
+	}
+	return nil
+}
+
+// Print writes cfg as the .env-style lines that would reproduce it, with
+// secret fields shown only as set or unset.
+func Print(w io.Writer, cfg any) error {
+	var err error
+	walk(reflect.ValueOf(cfg).Elem(), func(value reflect.Value, field reflect.StructField) {
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(w, "%s=%s\n", field.Tag.Get("env"), format(value, field.Tag.Get("secret") == "true"))
+	})
+	return err
+}
+
+func format(v reflect.Value, secret bool) string {
+	if secret {
+		if v.IsZero() {
+			return ""
+		}
+		return "[REDACTED]"
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		return value.String()
+	case []string:
+		return strings.Join(value, ",")
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,138 @@
+// Package problem is the JSON error envelope shared by grademyprofAPI and
+// grademyprofAuth. Errors are rendered as RFC 7807 application/problem+json
+// with a few extension members:
+//
+//	{
+//	  "type": "urn:grademyprof:error:rate_limited",
+//	  "title": "Too Many Requests",
+//	  "status": 429,
+//	  "detail": "Too many reviews submitted, try again in a minute",
+//	  "instance": "/api/professors/4/reviews",
+//	  "code": "rate_limited",
+//	  "message": "Too many reviews submitted, try again in a minute",
+//	  "details": {"limiter": "review_create"},
+//	  "request_id": "0f5c..."
+//	}
+//
+// Each service renders Problems with its own framework glue
+// (grademyprofAPI/fiberkit, grademyprofAuth/ginkit).
+package problem
+
+import "net/http"
+
+// ContentType is the media type of a rendered Problem.
+const ContentType = "application/problem+json"
+
+// Code is a stable, machine-readable error code. Clients switch on these,
+// so never rename or reuse one; add a new code instead.
+type Code string
+
+const (
+	CodeBadRequest       Code = "bad_request"
+	CodeInvalidBody      Code = "invalid_body"
+	CodeInvalidParameter Code = "invalid_parameter"
+	CodeMissingToken     Code = "missing_token"
+	CodeInvalidToken     Code = "invalid_token"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeConflict         Code = "conflict"
+	CodeDuplicateComment Code = "duplicate_comment"
+	CodeRestoreExpired   Code = "restore_expired"
+	CodeRateLimited      Code = "rate_limited"
+	CodeUpstream         Code = "upstream_error"
+	CodeAuthUnavailable  Code = "auth_unavailable"
+	CodeInternal         Code = "internal_error"
+
+	// Issued by grademyprofAuth during login
+	CodeInvalidFirebaseToken Code = "invalid_firebase_token"
+	CodeEmailMismatch        Code = "email_mismatch"
+	CodeEmailNotVerified     Code = "email_not_verified"
+)
+
+// Problem is both the error value handlers return and the response body.
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      Code        `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+func New(status int, code Code, message string) *Problem {
+	return &Problem{
+		Type:    "urn:grademyprof:error:" + string(code),
+		Title:   http.StatusText(status),
+		Status:  status,
+		Detail:  message,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (p *Problem) Error() string {
+	return string(p.Code) + ": " + p.Message
+}
+
+// WithDetails attaches structured context, e.g. which field failed.
+func (p *Problem) WithDetails(details interface{}) *Problem {
+	p.Details = details
+	return p
+}
+
+func BadRequest(code Code, message string) *Problem {
+	return New(http.StatusBadRequest, code, message)
+}
+
+func Unauthorized(code Code, message string) *Problem {
+	return New(http.StatusUnauthorized, code, message)
+}
+
+func Forbidden(message string) *Problem {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Problem {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func RateLimited(message string) *Problem {
+	return New(http.StatusTooManyRequests, CodeRateLimited, message)
+}
+
+// Upstream reports a failed call to a backing service such as Supabase.
+func Upstream(message string) *Problem {
+	return New(http.StatusInternalServerError, CodeUpstream, message)
+}
+
+func Internal(message string) *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// FromStatus picks a generic code for errors that only carry a status,
+// like the framework's own 404 and 405 responses.
+func FromStatus(status int, message string) *Problem {
+	code := CodeInternal
+	switch {
+	case status == http.StatusUnauthorized:
+		code = CodeUnauthorized
+	case status == http.StatusForbidden:
+		code = CodeForbidden
+	case status == http.StatusNotFound:
+		code = CodeNotFound
+	case status == http.StatusConflict:
+		code = CodeConflict
+	case status == http.StatusTooManyRequests:
+		code = CodeRateLimited
+	case status >= 400 && status < 500:
+		code = CodeBadRequest
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return New(status, code, message)
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how often Memory drops counters that have gone quiet.
+const sweepEvery = time.Minute
+
+type counter struct {
+	index      int64
+	prev, curr int
+	window     time.Duration
+}
+
+// Memory is an in-process Store. Counters are per replica, so with more
+// than one replica each of them enforces the limit separately.
+type Memory struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{counters: make(map[string]*counter), lastSweep: time.Now()}
+}
+
+func (m *Memory) Allow(_ context.Context, key string, limit int, window time.Duration) (Result, error) {
+	now := time.Now()
+	index, elapsed := slide(now, window)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > sweepEvery {
+		m.sweep(now)
+	}
+
+	c, ok := m.counters[key]
+	if !ok {
+		c = &counter{index: index, window: window}
+		m.counters[key] = c
+	}
+	switch {
+	case c.index == index-1:
+		c.prev, c.curr = c.curr, 0
+	case c.index != index:
+		c.prev, c.curr = 0, 0
+	}
+	c.index = index
+
+	allowed := estimate(c.prev, c.curr, elapsed, window) < limit
+	res := decide(allowed, limit, c.prev, c.curr, elapsed, window)
+	if allowed {
+		c.curr++
+	}
+	return res, nil
+}
+
+// sweep drops counters whose last request is two windows old; they would
+// count as zero anyway.
+func (m *Memory) sweep(now time.Time) {
+	for key, c := range m.counters {
+		if index, _ := slide(now, c.window); index-c.index >= 2 {
+			delete(m.counters, key)
+		}
+	}
+	m.lastSweep = now
+}
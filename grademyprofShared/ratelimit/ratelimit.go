@@ -0,0 +1,159 @@
+// Package ratelimit counts requests per key with a sliding window and keeps
+// the counters in a pluggable Store, so limits can hold across replicas.
+//
+// Each service counts its requests with its own framework glue
+// (grademyprofAPI/fiberkit, grademyprofAuth/ginkit).
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Response headers describing the quota (IETF draft-ietf-httpapi-ratelimit-headers).
+// RateLimit-Reset and Retry-After are in seconds.
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// Store holds the counters behind every Limiter. Allow records one request
+// against key and reports whether it fits in limit requests per window.
+//
+// Memory is the in-process implementation; Redis shares the counters
+// between replicas.
+type Store interface {
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error)
+}
+
+// Result describes a key's quota after a call to Allow.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Reset is when the current window ends; RetryAfter, set on rejected
+	// requests, is how long until the next request would be allowed.
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Limiter is one rate limit rule. Each Limiter's buckets are isolated from
+// every other Limiter's, even when they share a Store.
+type Limiter struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Store  Store
+}
+
+// Allow counts a request from identity (see UserKey and IPKey). When the
+// store fails the request is let through: a broken Redis shouldn't take the
+// whole site down with it.
+func (l *Limiter) Allow(ctx context.Context, identity string) Result {
+	res, err := l.Store.Allow(ctx, l.Name+":"+identity, l.Limit, l.Window)
+	if err != nil {
+		slog.WarnContext(ctx, "rate limit store unavailable, allowing request", "limiter", l.Name, "err", err)
+		return Result{Allowed: true, Limit: l.Limit, Remaining: l.Limit, Reset: l.Window}
+	}
+	return res
+}
+
+// UserKey identifies an authenticated user wherever they connect from, so
+// the students behind a shared campus NAT each get their own bucket and
+// switching networks doesn't reset it. The ID is hashed to keep emails out
+// of the store.
+func UserKey(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return "user:" + hex.EncodeToString(sum[:12])
+}
+
+// IPKey identifies an anonymous client.
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Headers renders res as response headers. Retry-After is only included
+// when the request was rejected.
+func (r Result) Headers() map[string]string {
+	headers := map[string]string{
+		HeaderLimit:     strconv.Itoa(r.Limit),
+		HeaderRemaining: strconv.Itoa(r.Remaining),
+		HeaderReset:     seconds(r.Reset),
+	}
+	if !r.Allowed {
+		headers[HeaderRetryAfter] = seconds(r.RetryAfter)
+	}
+	return headers
+}
+
+// Tighter reports whether r leaves no more requests than the
+// RateLimit-Remaining value an earlier limiter on the route already set,
+// so the headers always describe the limit the client hits first.
+func (r Result) Tighter(remaining string) bool {
+	n, err := strconv.Atoi(remaining)
+	return err != nil || r.Remaining <= n
+}
+
+// seconds rounds d up to whole seconds, so a client waiting that long is
+// never early.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
+
+// slide places now in the fixed window it falls into: the window's index
+// and how far into it now is.
+func slide(now time.Time, window time.Duration) (index int64, elapsed time.Duration) {
+	nanos := now.UnixNano()
+	index = nanos / int64(window)
+	return index, time.Duration(nanos - index*int64(window))
+}
+
+// estimate is the sliding window count: requests in the current fixed
+// window plus the previous window's, weighted by how much of it the sliding
+// window still covers.
+func estimate(prev, curr int, elapsed, window time.Duration) int {
+	weight := 1 - float64(elapsed)/float64(window)
+	return int(float64(prev)*weight) + curr
+}
+
+// decide builds the Result for a request that found prev and curr in the
+// store. allowed is whether it was counted.
+func decide(allowed bool, limit, prev, curr int, elapsed, window time.Duration) Result {
+	res := Result{Allowed: allowed, Limit: limit, Reset: window - elapsed}
+
+	used := estimate(prev, curr, elapsed, window)
+	if allowed {
+		used++
+	}
+	res.Remaining = max(limit-used, 0)
+
+	if !allowed {
+		res.RetryAfter = retryAfter(limit, prev, curr, elapsed, window)
+	}
+	return res
+}
+
+// retryAfter is how long until estimate drops below limit.
+func retryAfter(limit, prev, curr int, elapsed, window time.Duration) time.Duration {
+	if curr >= limit || prev == 0 {
+		// Nothing frees up before the window rolls over, at which point the
+		// full current window becomes the previous one
+		next := window - elapsed
+		if curr > limit {
+			next += time.Duration(float64(window) * (1 - float64(limit)/float64(curr)))
+		}
+		return next
+	}
+
+	// The previous window's share falls below limit-curr once
+	// prev*(1-(elapsed+t)/window) < limit-curr
+	t := time.Duration(float64(window)*(1-float64(limit-curr)/float64(prev))) - elapsed
+	return max(t, time.Millisecond)
+}
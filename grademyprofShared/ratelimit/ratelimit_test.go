@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, curr int
+		elapsed    time.Duration
+		want       int
+	}{
+		{"start of window counts all of the previous one", 10, 0, 0, 10},
+		{"halfway counts half of it", 10, 2, 30 * time.Second, 7},
+		{"partial requests round down", 3, 1, 30 * time.Second, 2},
+		{"end of window only counts the current one", 10, 4, time.Minute, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimate(tt.prev, tt.curr, tt.elapsed, time.Minute); got != tt.want {
+				t.Errorf("estimate(%d, %d, %s) = %d, want %d", tt.prev, tt.curr, tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name              string
+		limit, prev, curr int
+		elapsed           time.Duration
+		want              time.Duration
+	}{
+		{"full current window waits for the rollover", 5, 0, 5, 20 * time.Second, 40 * time.Second},
+		{"over the limit waits past the rollover", 5, 0, 10, 20 * time.Second, 70 * time.Second},
+		{"previous window's share decays", 5, 10, 2, 30 * time.Second, 12 * time.Second},
+		{"about to free up waits at least a millisecond", 5, 10, 2, 42 * time.Second, time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := retryAfter(tt.limit, tt.prev, tt.curr, tt.elapsed, time.Minute)
+			if got != tt.want {
+				t.Errorf("retryAfter = %s, want %s", got, tt.want)
+			}
+			// Waiting that long must actually let a request through
+			if elapsed := tt.elapsed + got + time.Millisecond; elapsed < time.Minute &&
+				estimate(tt.prev, tt.curr, elapsed, time.Minute) >= tt.limit {
+				t.Errorf("still limited %s later", got)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowed       bool
+		prev, curr    int
+		wantRemaining int
+		wantRetry     bool
+	}{
+		{"allowed request counts itself", true, 0, 2, 2, false},
+		{"last allowed request leaves none", true, 0, 4, 0, false},
+		{"rejected request says when to retry", false, 0, 5, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := decide(tt.allowed, 5, tt.prev, tt.curr, 15*time.Second, time.Minute)
+			if res.Remaining != tt.wantRemaining {
+				t.Errorf("Remaining = %d, want %d", res.Remaining, tt.wantRemaining)
+			}
+			if res.Reset != 45*time.Second {
+				t.Errorf("Reset = %s, want 45s", res.Reset)
+			}
+			if (res.RetryAfter > 0) != tt.wantRetry {
+				t.Errorf("RetryAfter = %s, want set: %t", res.RetryAfter, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		res       Result
+		wantRetry string
+	}{
+		{"allowed", Result{Allowed: true, Limit: 5, Remaining: 3, Reset: 1500 * time.Millisecond}, ""},
+		{"rejected rounds up", Result{Limit: 5, Reset: 1500 * time.Millisecond, RetryAfter: 100 * time.Millisecond}, "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := tt.res.Headers()
+			if headers[HeaderReset] != "2" {
+				t.Errorf("%s = %q, want 2", HeaderReset, headers[HeaderReset])
+			}
+			if headers[HeaderRetryAfter] != tt.wantRetry {
+				t.Errorf("%s = %q, want %q", HeaderRetryAfter, headers[HeaderRetryAfter], tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	// A window long enough that the test never crosses into the next one
+	store := NewMemory()
+	reviews := &Limiter{Name: "reviews", Limit: 3, Window: 1000 * time.Hour, Store: store}
+	global := &Limiter{Name: "global", Limit: 3, Window: 1000 * time.Hour, Store: store}
+	ctx := context.Background()
+
+	for i, want := range []int{2, 1, 0} {
+		res := reviews.Allow(ctx, UserKey("student@pilani.bits-pilani.ac.in"))
+		if !res.Allowed || res.Remaining != want {
+			t.Fatalf("request %d: got allowed %t, remaining %d, want remaining %d", i+1, res.Allowed, res.Remaining, want)
+		}
+	}
+	res := reviews.Allow(ctx, UserKey("student@pilani.bits-pilani.ac.in"))
+	if res.Allowed || res.RetryAfter <= 0 {
+		t.Fatalf("4th request: got allowed %t, retry after %s, want rejected with a retry time", res.Allowed, res.RetryAfter)
+	}
+
+	// Other users and other limiters have their own buckets
+	if res := reviews.Allow(ctx, UserKey("other@pilani.bits-pilani.ac.in")); !res.Allowed {
+		t.Error("another user was limited")
+	}
+	if res := global.Allow(ctx, UserKey("student@pilani.bits-pilani.ac.in")); !res.Allowed {
+		t.Error("another limiter was limited")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	email := "Student@pilani.bits-pilani.ac.in"
+	if key := UserKey(email); strings.Contains(key, "pilani") || key != UserKey(email) {
+		t.Errorf("UserKey(%q) = %q, want a stable key without the email", email, key)
+	}
+	if UserKey(email) == UserKey("other@pilani.bits-pilani.ac.in") {
+		t.Error("two users share a key")
+	}
+	if UserKey(email) == IPKey("10.0.0.1") {
+		t.Error("a user and an IP share a key")
+	}
+}
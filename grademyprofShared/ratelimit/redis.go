@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// allowScript applies the same check as Memory atomically on the server.
+// KEYS are the current and previous window's counters; ARGV are the
+// previous window's weight, the limit and the counters' lifetime in ms.
+var allowScript = redis.NewScript(`
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+if math.floor(prev * tonumber(ARGV[1])) + curr >= tonumber(ARGV[2]) then
+	return {0, prev, curr}
+end
+redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return {1, prev, curr}
+`)
+
+func init() {
+	redis.SetLogger(redisLogger{})
+}
+
+// redisLogger sends go-redis's internal messages to slog. They're debug
+// level: Limiter.Allow already warns when a call fails.
+type redisLogger struct{}
+
+func (redisLogger) Printf(ctx context.Context, format string, v ...any) {
+	slog.DebugContext(ctx, "redis: "+fmt.Sprintf(format, v...))
+}
+
+// Redis is a Store on any server that speaks the Redis protocol and runs
+// Lua scripts (Redis, Valkey, KeyDB, ...), so every replica shares limits.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis connects to url, e.g. redis://:password@host:6379/0.
+func NewRedis(url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{client: redis.NewClient(opts)}, nil
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, limit int, window time.Duration) (Result, error) {
+	index, elapsed := slide(time.Now(), window)
+	keys := []string{
+		"ratelimit:" + key + ":" + strconv.FormatInt(index, 10),
+		"ratelimit:" + key + ":" + strconv.FormatInt(index-1, 10),
+	}
+	weight := 1 - float64(elapsed)/float64(window)
+	ttl := (2 * window).Milliseconds()
+
+	reply, err := allowScript.Run(ctx, r.client, keys, weight, limit, ttl).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return decide(reply[0] == 1, limit, int(reply[1]), int(reply[2]), elapsed, window), nil
+}
+
+// Ping checks the server is reachable.
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
@@ -24,7 +24,8 @@ interface Professor {
 interface Review {
   id: number;
   professor_id: number;
-  user_email: string;
+  reviewer_id: string | null;
+  reviewer_name: string;
   student_name: string;
   rating: number;
   difficulty: number;
@@ -35,7 +36,7 @@ interface Review {
 }
 
 // API Configuration
-const API_BASE_URL = import.meta.env.VITE_API_URL || "http://localhost:4000/api";
+const API_BASE_URL = import.meta.env.VITE_API_URL || "http://localhost:4000/api/v1";
 
 function App() {
   const [jwtToken, setJwtToken] = useState<string | null>(null);
@@ -90,9 +91,7 @@ function App() {
 
     try {
       const response = await fetch(
-        `${API_BASE_URL}/professors/${professorId}/user-review?user_email=${encodeURIComponent(
-          user.email
-        )}`,
+        `${API_BASE_URL}/professors/${professorId}/user-review`,
         {
           headers: {
             Authorization: `Bearer ${jwtToken}`,
@@ -293,7 +292,7 @@ function App() {
       if (!response.ok) {
         const errorData = await response.json().catch(() => ({}));
         console.error("Delete failed:", errorData);
-        throw new Error(errorData.error || "Failed to delete review");
+        throw new Error(errorData.message || "Failed to delete review");
       }
 
       console.log("✅ Review deleted successfully");
@@ -650,7 +649,7 @@ function App() {
                   <ReviewCard
                     key={review.id}
                     review={review}
-                    currentUserEmail={user?.email}
+                    currentUserReviewId={userExistingReview?.id}
                     onDelete={deleteReview}
                   />
                 ))}
@@ -670,7 +669,6 @@ function App() {
           <ReviewForm
             professorId={selectedProfessor.id}
             professorName={selectedProfessor.name}
-            user={user}
             jwtToken={jwtToken || ""}
             existingReview={userExistingReview}
             onReviewSubmitted={handleReviewSubmitted}
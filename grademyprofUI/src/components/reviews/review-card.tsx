@@ -4,7 +4,8 @@ import { StarRating } from "../ui/star-rating";
 
 interface Review {
   id: number;
-  user_email: string;
+  reviewer_id: string | null;
+  reviewer_name: string;
   student_name: string;
   rating: number;
   difficulty: number;
@@ -16,13 +17,13 @@ interface Review {
 
 interface ReviewCardProps {
   review: Review;
-  currentUserEmail?: string | null;
+  currentUserReviewId?: number | null;
   onDelete?: (reviewId: number) => void;
 }
 
 export function ReviewCard({
   review,
-  currentUserEmail,
+  currentUserReviewId,
   onDelete,
 }: ReviewCardProps) {
   const formatDate = (dateString: string) => {
@@ -33,8 +34,9 @@ export function ReviewCard({
     });
   };
 
+  // Reviews don't carry emails; the user's own comes from /user-review
   const isOwnReview =
-    currentUserEmail && review.user_email === currentUserEmail;
+    currentUserReviewId != null && review.id === currentUserReviewId;
 
   return (
     <Card className="w-full bg-gray-800 border-gray-600">
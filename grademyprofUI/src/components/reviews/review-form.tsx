@@ -9,11 +9,6 @@ import { StarRating } from "../ui/star-rating";
 interface ReviewFormProps {
   professorId: number;
   professorName: string;
-  user: {
-    email: string;
-    name: string | null;
-    campus: string;
-  };
   jwtToken: string;
   existingReview?: {
     id: number;
@@ -37,12 +32,11 @@ interface ReviewData {
   comment: string;
 }
 
-const API_BASE_URL = import.meta.env.VITE_API_URL || "http://localhost:4000/api";
+const API_BASE_URL = import.meta.env.VITE_API_URL || "http://localhost:4000/api/v1";
 
 export function ReviewForm({
   professorId,
   professorName,
-  user,
   jwtToken,
   existingReview,
   onReviewSubmitted,
@@ -67,9 +61,8 @@ export function ReviewForm({
     setError(null);
 
     try {
-      // Create reviewData that includes user_email
+      // The API takes the author from the token
       const reviewData = {
-        user_email: user.email,
         student_name: formData.student_name,
         rating: formData.rating,
         difficulty: formData.difficulty,
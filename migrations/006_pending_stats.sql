@@ -0,0 +1,16 @@
+-- Professors whose stats recomputation was still running when a
+-- grademyprofAPI instance shut down. The next instance recomputes them on
+-- startup and deletes each row once its recomputation succeeded.
+CREATE TABLE IF NOT EXISTS pending_stats (
+    professor_id INTEGER PRIMARY KEY REFERENCES professor(id) ON DELETE CASCADE,
+    queued_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Enable Row Level Security
+ALTER TABLE pending_stats ENABLE ROW LEVEL SECURITY;
+
+-- The API writes with the anon key
+CREATE POLICY "Anyone can read pending stats" ON pending_stats FOR SELECT USING (true);
+CREATE POLICY "Anyone can queue pending stats" ON pending_stats FOR INSERT WITH CHECK (true);
+CREATE POLICY "Anyone can update pending stats" ON pending_stats FOR UPDATE USING (true);
+CREATE POLICY "Anyone can claim pending stats" ON pending_stats FOR DELETE USING (true);
@@ -0,0 +1,11 @@
+-- grademyprofAPI's abuse detector (abuse.go) flags suspicious reviews and
+-- holds some of them for moderation. Only approved reviews are listed
+-- publicly or counted in professor stats; existing reviews stay approved.
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderation_status VARCHAR(20) NOT NULL DEFAULT 'approved'
+    CHECK (moderation_status IN ('approved', 'held', 'rejected'));
+
+-- Why the detector flagged a review: review_burst, new_account, near_duplicate
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderation_flags TEXT[] NOT NULL DEFAULT '{}';
+
+-- The moderation queue lists one status oldest first
+CREATE INDEX IF NOT EXISTS idx_reviews_moderation_status ON reviews(moderation_status, created_at);
@@ -0,0 +1,14 @@
+-- grademyprofAPI fingerprints every review comment (fingerprint package) to
+-- catch the same text posted against different professors. NULL means not
+-- computed yet: the API backfills those rows at startup.
+-- MinHash signature, 128 unsigned 32-bit values
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS comment_minhash BIGINT[];
+
+-- LSH band keys; reviews sharing any key are compared exactly
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS comment_bands TEXT[];
+
+-- Candidate lookup: comment_bands && '{...}'
+CREATE INDEX IF NOT EXISTS idx_reviews_comment_bands ON reviews USING GIN (comment_bands);
+
+-- Backfill scan
+CREATE INDEX IF NOT EXISTS idx_reviews_unfingerprinted ON reviews(id) WHERE comment_minhash IS NULL;
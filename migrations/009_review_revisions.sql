@@ -0,0 +1,38 @@
+-- grademyprofAPI keeps every version of a review (history.go): revision 1
+-- is the review as first posted, and each edit adds the next one.
+CREATE TABLE IF NOT EXISTS review_revisions (
+    review_id INTEGER NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL CHECK (revision >= 1),
+    editor_email VARCHAR(255) NOT NULL,
+    edited_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    student_name VARCHAR(100) NOT NULL,
+    rating DECIMAL(2,1) NOT NULL,
+    difficulty DECIMAL(2,1) NOT NULL,
+    would_take_again BOOLEAN NOT NULL,
+    course VARCHAR(100) NOT NULL,
+    comment TEXT,
+    PRIMARY KEY (review_id, revision)
+);
+
+-- When a review was last edited, and how many times
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE;
+UPDATE reviews SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE reviews ALTER COLUMN updated_at SET DEFAULT NOW();
+ALTER TABLE reviews ALTER COLUMN updated_at SET NOT NULL;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS edit_count INTEGER NOT NULL DEFAULT 0;
+
+-- Last-Modified uses the most recently written review
+CREATE INDEX IF NOT EXISTS idx_reviews_updated_at ON reviews(updated_at DESC);
+
+-- Existing reviews start their history as they are now
+INSERT INTO review_revisions (review_id, revision, editor_email, edited_at, student_name, rating, difficulty, would_take_again, course, comment)
+SELECT id, 1, user_email, created_at, student_name, rating, difficulty, would_take_again, course, comment FROM reviews
+ON CONFLICT DO NOTHING;
+
+-- Enable Row Level Security
+ALTER TABLE review_revisions ENABLE ROW LEVEL SECURITY;
+
+-- The API writes with the anon key. Revisions are append-only: no update
+-- or delete policy.
+CREATE POLICY "Anyone can read review revisions" ON review_revisions FOR SELECT USING (true);
+CREATE POLICY "Anyone can add review revisions" ON review_revisions FOR INSERT WITH CHECK (true);
@@ -0,0 +1,13 @@
+-- grademyprofAPI soft-deletes reviews (deletion.go): deleted_at is set, the
+-- review leaves listings and stats, and its author can restore it until the
+-- API's purge job removes it after REVIEW_RESTORE_WINDOW.
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- A user may write a new review of a professor while their deleted one
+-- waits to be purged, so only live reviews have to be unique.
+ALTER TABLE reviews DROP CONSTRAINT IF EXISTS unique_user_professor_review;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_user_professor_live_review
+    ON reviews(user_email, professor_id) WHERE deleted_at IS NULL;
+
+-- The purge job looks up expired tombstones
+CREATE INDEX IF NOT EXISTS idx_reviews_deleted_at ON reviews(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,6 @@
+-- DELETE /api/me (grademyprofAPI account.go) scrubs the user's name and
+-- email from the edit history. Revisions are otherwise never changed.
+CREATE POLICY "Anyone can anonymize review revisions" ON review_revisions FOR UPDATE USING (true);
+
+-- Finding the revisions a user wrote
+CREATE INDEX IF NOT EXISTS idx_review_revisions_editor_email ON review_revisions(editor_email);
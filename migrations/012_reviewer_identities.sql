@@ -0,0 +1,35 @@
+-- grademyprofAPI never shows who wrote a review (reviewers.go): each user
+-- gets an opaque ID and a random pseudonym, and may choose a handle to show
+-- instead. Email stays the key for sign-in and ownership checks only.
+CREATE TABLE IF NOT EXISTS reviewers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email VARCHAR(255) NOT NULL UNIQUE,
+    pseudonym VARCHAR(50) NOT NULL UNIQUE,
+    handle VARCHAR(24) CHECK (handle ~ '^[A-Za-z0-9_]{3,24}$'),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Handles are unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS unique_reviewer_handle ON reviewers(LOWER(handle));
+
+-- Who wrote each review, and the name to show for them: the handle, else
+-- the pseudonym. Existing reviews show "Anonymous" until the API backfills
+-- them at startup; reviews of deleted accounts keep no reviewer.
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS reviewer_id UUID REFERENCES reviewers(id) ON DELETE SET NULL;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS reviewer_name VARCHAR(50) NOT NULL DEFAULT 'Anonymous';
+
+-- Renaming a reviewer's reviews
+CREATE INDEX IF NOT EXISTS idx_reviews_reviewer_id ON reviews(reviewer_id);
+
+-- Backfill scan
+CREATE INDEX IF NOT EXISTS idx_reviews_unattributed ON reviews(user_email) WHERE reviewer_id IS NULL;
+
+-- Enable Row Level Security
+ALTER TABLE reviewers ENABLE ROW LEVEL SECURITY;
+
+-- The API writes with the anon key, so like reviews.user_email the emails
+-- here are only kept out of API responses, not out of the database.
+CREATE POLICY "Anyone can read reviewers" ON reviewers FOR SELECT USING (true);
+CREATE POLICY "Anyone can add reviewers" ON reviewers FOR INSERT WITH CHECK (true);
+CREATE POLICY "Anyone can change reviewer handles" ON reviewers FOR UPDATE USING (true);
+CREATE POLICY "Anyone can delete reviewers" ON reviewers FOR DELETE USING (true);
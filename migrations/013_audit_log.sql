@@ -0,0 +1,49 @@
+-- Both services record privileged and destructive actions here (audit
+-- package): logins and token revocations in grademyprofAuth, review edits,
+-- deletes, restores, purges, moderation and account deletion in
+-- grademyprofAPI. Admins read it through GET /api/admin/audit.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    service VARCHAR(50) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    actor_role VARCHAR(20),
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(50) NOT NULL,
+    target_id VARCHAR(255),
+    before JSONB,
+    after JSONB,
+    request_id VARCHAR(100),
+    ip VARCHAR(45)
+);
+
+-- Filters, newest first
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_request_id ON audit_log(request_id);
+
+-- Append-only, whoever asks: entries can't be changed or removed, not even
+-- with the service role key
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
+
+-- Enable Row Level Security
+ALTER TABLE audit_log ENABLE ROW LEVEL SECURITY;
+
+-- The services write with the anon key: no update or delete policy.
+CREATE POLICY "Anyone can read the audit log" ON audit_log FOR SELECT USING (true);
+CREATE POLICY "Anyone can add audit entries" ON audit_log FOR INSERT WITH CHECK (true);
@@ -0,0 +1,61 @@
+-- Admins subscribe URLs to review and professor stats events through
+-- /api/admin/webhooks. grademyprofAPI queues one delivery per subscription
+-- and event, POSTs it signed with the subscription's secret and retries
+-- failures with exponential backoff; the deliveries double as the delivery
+-- log admins read and replay from.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    secret VARCHAR(100) NOT NULL,
+    description VARCHAR(200) NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (events <@ ARRAY['review.created', 'review.updated', 'review.deleted', 'professor.stats_changed']::TEXT[]),
+    CHECK (cardinality(events) > 0)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    -- A subscription's log goes with it
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    -- Shared by every delivery of one event, replays included
+    event_id UUID NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- When a pending delivery is due; NULL once it's settled
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    last_attempt_at TIMESTAMP WITH TIME ZONE,
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    replay_of BIGINT REFERENCES webhook_deliveries(id) ON DELETE SET NULL
+);
+
+-- The delivery worker's queue
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- A subscription's log, newest first
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, id DESC);
+
+-- Finding subscribers to an event
+CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_events ON webhook_subscriptions USING GIN (events) WHERE active;
+
+-- Enable Row Level Security
+ALTER TABLE webhook_subscriptions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+
+-- The API reads and writes with the anon key, so like reviewers.email the
+-- signing secrets are only kept out of API responses, not out of the
+-- database.
+CREATE POLICY "Anyone can read webhook subscriptions" ON webhook_subscriptions FOR SELECT USING (true);
+CREATE POLICY "Anyone can add webhook subscriptions" ON webhook_subscriptions FOR INSERT WITH CHECK (true);
+CREATE POLICY "Anyone can change webhook subscriptions" ON webhook_subscriptions FOR UPDATE USING (true);
+CREATE POLICY "Anyone can delete webhook subscriptions" ON webhook_subscriptions FOR DELETE USING (true);
+CREATE POLICY "Anyone can read webhook deliveries" ON webhook_deliveries FOR SELECT USING (true);
+CREATE POLICY "Anyone can queue webhook deliveries" ON webhook_deliveries FOR INSERT WITH CHECK (true);
+CREATE POLICY "Anyone can update webhook deliveries" ON webhook_deliveries FOR UPDATE USING (true);
@@ -0,0 +1,37 @@
+-- When anything shown on a professor's pages last changed, for the
+-- Last-Modified header on professor and review responses (grademyprofAPI
+-- conditional.go). Bumped by the database rather than by the API so no
+-- write path can forget it: review inserts, edits, soft deletes, restores,
+-- moderation, purges and account deletion all go through the reviews
+-- trigger, stats updates through the professor one.
+ALTER TABLE professor ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+CREATE OR REPLACE FUNCTION professor_touch() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at := NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS professor_touch ON professor;
+CREATE TRIGGER professor_touch
+    BEFORE UPDATE ON professor
+    FOR EACH ROW EXECUTE FUNCTION professor_touch();
+
+-- A review moved to another professor changes both
+CREATE OR REPLACE FUNCTION reviews_touch_professor() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP <> 'DELETE' THEN
+        UPDATE professor SET updated_at = NOW() WHERE id = NEW.professor_id;
+    END IF;
+    IF TG_OP <> 'INSERT' AND (TG_OP = 'DELETE' OR OLD.professor_id IS DISTINCT FROM NEW.professor_id) THEN
+        UPDATE professor SET updated_at = NOW() WHERE id = OLD.professor_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS reviews_touch_professor ON reviews;
+CREATE TRIGGER reviews_touch_professor
+    AFTER INSERT OR UPDATE OR DELETE ON reviews
+    FOR EACH ROW EXECUTE FUNCTION reviews_touch_professor();
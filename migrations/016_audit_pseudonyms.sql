@@ -0,0 +1,25 @@
+-- The audit log outlives accounts, so it keeps no emails or IPs: the
+-- services write keyed-hash pseudonyms instead (audit.Pseudonym, keyed by
+-- AUDIT_KEY). The key never reaches the database, so entries written before
+-- this migration can't be converted; their emails and IPs are dropped.
+BEGIN;
+
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_append_only;
+
+UPDATE audit_log SET actor = 'redacted' WHERE actor LIKE '%@%';
+UPDATE audit_log SET target_id = 'redacted' WHERE target_id LIKE '%@%';
+UPDATE audit_log SET ip = NULL WHERE ip IS NOT NULL;
+UPDATE audit_log SET before = before - 'created_by' WHERE before ? 'created_by';
+UPDATE audit_log SET after = after - 'created_by' WHERE after ? 'created_by';
+
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_append_only;
+
+-- And none sneak back in
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_no_emails;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_no_emails
+    CHECK (actor NOT LIKE '%@%' AND (target_id IS NULL OR target_id NOT LIKE '%@%'));
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_ip_pseudonym;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_ip_pseudonym
+    CHECK (ip IS NULL OR ip ~ '^[0-9a-f]{32}$');
+
+COMMIT;
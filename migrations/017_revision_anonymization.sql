@@ -0,0 +1,33 @@
+-- 011 let anyone with the anon key rewrite review history. DELETE /api/me
+-- (grademyprofAPI account.go) now scrubs it through this function instead,
+-- which only the service role may call and which only replaces the
+-- deleted user's email and name: edits they made get anonymous_id as
+-- editor, and revisions of their anonymized reviews (already handed over
+-- to anonymous_id) lose the student name. Returns the revisions changed.
+DROP POLICY IF EXISTS "Anyone can anonymize review revisions" ON review_revisions;
+
+CREATE OR REPLACE FUNCTION anonymize_review_revisions(email TEXT, anonymous_id TEXT)
+RETURNS INTEGER AS $$
+DECLARE
+    edited INTEGER;
+    renamed INTEGER;
+BEGIN
+    IF anonymous_id NOT LIKE 'deleted:%' THEN
+        RAISE EXCEPTION 'anonymous_id must be a deleted: ID';
+    END IF;
+
+    UPDATE review_revisions SET editor_email = anonymous_id
+        WHERE editor_email = anonymize_review_revisions.email;
+    GET DIAGNOSTICS edited = ROW_COUNT;
+
+    -- anonymousName in account.go
+    UPDATE review_revisions SET student_name = 'Anonymous'
+        WHERE review_id IN (SELECT id FROM reviews WHERE user_email = anonymous_id);
+    GET DIAGNOSTICS renamed = ROW_COUNT;
+
+    RETURN edited + renamed;
+END;
+$$ LANGUAGE plpgsql SECURITY DEFINER SET search_path = public;
+
+REVOKE ALL ON FUNCTION anonymize_review_revisions(TEXT, TEXT) FROM PUBLIC, anon, authenticated;
+GRANT EXECUTE ON FUNCTION anonymize_review_revisions(TEXT, TEXT) TO service_role;
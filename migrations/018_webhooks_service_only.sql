@@ -0,0 +1,17 @@
+-- 014 let anyone with the anon key read webhook signing secrets and point
+-- subscriptions anywhere. grademyprofAPI now reaches the webhook tables with
+-- the service role key (SUPABASE_SERVICE_ROLE_KEY), which bypasses row
+-- level security, so the tables get no policies at all and the anon and
+-- authenticated roles lose their grants.
+DROP POLICY IF EXISTS "Anyone can read webhook subscriptions" ON webhook_subscriptions;
+DROP POLICY IF EXISTS "Anyone can add webhook subscriptions" ON webhook_subscriptions;
+DROP POLICY IF EXISTS "Anyone can change webhook subscriptions" ON webhook_subscriptions;
+DROP POLICY IF EXISTS "Anyone can delete webhook subscriptions" ON webhook_subscriptions;
+DROP POLICY IF EXISTS "Anyone can read webhook deliveries" ON webhook_deliveries;
+DROP POLICY IF EXISTS "Anyone can queue webhook deliveries" ON webhook_deliveries;
+DROP POLICY IF EXISTS "Anyone can update webhook deliveries" ON webhook_deliveries;
+
+REVOKE ALL ON webhook_subscriptions, webhook_deliveries FROM anon, authenticated;
+REVOKE ALL ON SEQUENCE webhook_subscriptions_id_seq, webhook_deliveries_id_seq FROM anon, authenticated;
+GRANT ALL ON webhook_subscriptions, webhook_deliveries TO service_role;
+GRANT USAGE, SELECT ON SEQUENCE webhook_subscriptions_id_seq, webhook_deliveries_id_seq TO service_role;
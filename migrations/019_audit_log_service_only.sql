@@ -0,0 +1,14 @@
+-- 013 let anyone with the anon key read the audit log and add entries to
+-- it. Both services now write it with the service role key
+-- (SUPABASE_SERVICE_ROLE_KEY), which bypasses row level security, and
+-- admins read it through GET /api/admin/audit, so the table gets no
+-- policies and the anon and authenticated roles lose their grants. The
+-- service role only needs to read and append: the trigger from 013 still
+-- refuses updates and deletes.
+DROP POLICY IF EXISTS "Anyone can read the audit log" ON audit_log;
+DROP POLICY IF EXISTS "Anyone can add audit entries" ON audit_log;
+
+REVOKE ALL ON audit_log FROM anon, authenticated;
+REVOKE ALL ON SEQUENCE audit_log_id_seq FROM anon, authenticated;
+GRANT SELECT, INSERT ON audit_log TO service_role;
+GRANT USAGE, SELECT ON SEQUENCE audit_log_id_seq TO service_role;
@@ -0,0 +1,13 @@
+-- 012 let anyone with the anon key read the email to pseudonym mapping,
+-- rename any reviewer and delete reviewers. grademyprofAPI now reaches the
+-- table with the service role key (SUPABASE_SERVICE_ROLE_KEY), which
+-- bypasses row level security, so the table gets no policies and the anon
+-- and authenticated roles lose their grants. reviews.reviewer_id still
+-- references it: foreign key checks don't need the writer to see reviewers.
+DROP POLICY IF EXISTS "Anyone can read reviewers" ON reviewers;
+DROP POLICY IF EXISTS "Anyone can add reviewers" ON reviewers;
+DROP POLICY IF EXISTS "Anyone can change reviewer handles" ON reviewers;
+DROP POLICY IF EXISTS "Anyone can delete reviewers" ON reviewers;
+
+REVOKE ALL ON reviewers FROM anon, authenticated;
+GRANT ALL ON reviewers TO service_role;
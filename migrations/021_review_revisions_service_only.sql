@@ -0,0 +1,12 @@
+-- 009 let anyone with the anon key read every revision, editor emails and
+-- the comments of deleted and moderated reviews included, and add forged
+-- ones. grademyprofAPI now reads and writes revisions with the service role
+-- key (SUPABASE_SERVICE_ROLE_KEY), which bypasses row level security, so
+-- the table gets no policies and the anon and authenticated roles lose
+-- their grants. Revisions stay append-only for the API; 017's function
+-- still scrubs them when an account is deleted.
+DROP POLICY IF EXISTS "Anyone can read review revisions" ON review_revisions;
+DROP POLICY IF EXISTS "Anyone can add review revisions" ON review_revisions;
+
+REVOKE ALL ON review_revisions FROM anon, authenticated;
+GRANT SELECT, INSERT ON review_revisions TO service_role;
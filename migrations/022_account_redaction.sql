@@ -0,0 +1,84 @@
+-- DELETE /api/me (grademyprofAPI account.go) scrubbed reviews, revisions and
+-- the reviewer, but webhook deliveries kept the deleted user's reviews in
+-- their payloads, ready to be replayed, and audit entries kept them in
+-- their before/after snapshots. redact_account_data rewrites both, as only
+-- the service role may call it.
+
+-- review with the author's name, reviewer and email gone, and its comment
+-- too unless keep_comment (anonymized reviews stay public). Keys review
+-- doesn't have aren't added: moderation snapshots only hold the status.
+CREATE OR REPLACE FUNCTION redact_review_json(review JSONB, keep_comment BOOLEAN)
+RETURNS JSONB AS $$
+DECLARE
+    redacted JSONB := review - 'user_email';
+BEGIN
+    IF review IS NULL OR jsonb_typeof(review) <> 'object' THEN
+        RETURN review;
+    END IF;
+    -- anonymousName in account.go
+    IF redacted ? 'student_name' THEN
+        redacted := redacted || '{"student_name": "Anonymous"}';
+    END IF;
+    IF redacted ? 'reviewer_name' THEN
+        redacted := redacted || '{"reviewer_name": "Anonymous"}';
+    END IF;
+    IF redacted ? 'reviewer_id' THEN
+        redacted := redacted || '{"reviewer_id": null}';
+    END IF;
+    IF redacted ? 'comment' AND NOT keep_comment THEN
+        redacted := redacted || '{"comment": ""}';
+    END IF;
+    RETURN redacted;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Still append-only, except for redact_account_data: nobody else has
+-- UPDATE on audit_log (019), and it may only change the snapshots.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND current_setting('audit_log.redacting', true) = 'on'
+        AND (NEW.id, NEW.created_at, NEW.service, NEW.actor, NEW.actor_role, NEW.action,
+             NEW.target_type, NEW.target_id, NEW.request_id, NEW.ip)
+        IS NOT DISTINCT FROM
+            (OLD.id, OLD.created_at, OLD.service, OLD.actor, OLD.actor_role, OLD.action,
+             OLD.target_type, OLD.target_id, OLD.request_id, OLD.ip) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- Redacts the reviews with review_ids, and any other review by reviewer_id
+-- (e.g. purged ones), in review.* webhook payloads and in audit snapshots.
+-- Comments are kept when keep_comments, as for ACCOUNT_DELETION_REVIEWS=
+-- anonymize. Returns the deliveries and audit entries changed.
+CREATE OR REPLACE FUNCTION redact_account_data(review_ids INTEGER[], reviewer_id UUID, keep_comments BOOLEAN)
+RETURNS INTEGER AS $$
+DECLARE
+    ids TEXT[] := review_ids::TEXT[];
+    reviewer TEXT := reviewer_id::TEXT;
+    deliveries INTEGER;
+    entries INTEGER;
+BEGIN
+    UPDATE webhook_deliveries
+        SET payload = jsonb_set(payload, '{data}', redact_review_json(payload->'data', keep_comments))
+        WHERE event LIKE 'review.%'
+            AND (payload->'data'->>'id' = ANY(ids) OR payload->'data'->>'reviewer_id' = reviewer);
+    GET DIAGNOSTICS deliveries = ROW_COUNT;
+
+    PERFORM set_config('audit_log.redacting', 'on', true);
+    UPDATE audit_log
+        SET before = redact_review_json(before, keep_comments),
+            after = redact_review_json(after, keep_comments)
+        WHERE (target_type = 'review' AND target_id = ANY(ids))
+            OR before->>'reviewer_id' = reviewer
+            OR after->>'reviewer_id' = reviewer;
+    GET DIAGNOSTICS entries = ROW_COUNT;
+    PERFORM set_config('audit_log.redacting', 'off', true);
+
+    RETURN deliveries + entries;
+END;
+$$ LANGUAGE plpgsql SECURITY DEFINER SET search_path = public;
+
+REVOKE ALL ON FUNCTION redact_account_data(INTEGER[], UUID, BOOLEAN) FROM PUBLIC, anon, authenticated;
+GRANT EXECUTE ON FUNCTION redact_account_data(INTEGER[], UUID, BOOLEAN) TO service_role;